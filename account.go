@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// accountEnv は、-account の既定値を指定する環境変数です。
+const accountEnv = "YOUTUBE_ACCOUNT"
+
+// account は、トークンのキャッシュを分けるためのアカウントの名前です。
+// 空の場合は、以前から使っている1つのキャッシュファイルを使います。
+var account = os.Getenv(accountEnv)
+
+// accountNamePattern は、キャッシュファイルの名前に使えるアカウント名の形式です。
+var accountNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// addAccountFlag は、-account をフラグセットに登録します。
+// 認証を行うすべてのコマンドで同じ意味になるよう、このフラグはここでまとめて定義します。
+func addAccountFlag(fs *flag.FlagSet) {
+	fs.StringVar(&account, "account", account,
+		"Name of the account whose cached token to use, so several channels can stay logged in (default $"+accountEnv+")")
+}
+
+// validateAccount は、-account の値がキャッシュファイルの名前に使えるかどうかを検証します。
+func validateAccount(name string) error {
+	if name != "" && !accountNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid -account %q, use only letters, digits, '.', '_' and '-'", name)
+	}
+	return nil
+}
+
+// tokenCacheName は、アカウントに対応するトークンのキャッシュファイルの名前を返します。
+func tokenCacheName(name string) string {
+	if name == "" {
+		return "youtube-go.json"
+	}
+	return "youtube-go-" + name + ".json"
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// optionalBool は、指定されなかったことと false を区別する真偽値のフラグです。
+// -made-for-kids のように、省略を許さず明示的な宣言を求める項目に使います。
+type optionalBool struct {
+	value *bool
+}
+
+func (b *optionalBool) String() string {
+	if b.value == nil {
+		return ""
+	}
+	return strconv.FormatBool(*b.value)
+}
+
+func (b *optionalBool) Set(s string) error {
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("must be true or false")
+	}
+	b.value = &v
+	return nil
+}
+
+// applyMadeForKids は、メタデータで視聴者が宣言されていない場合に -made-for-kids の値を設定し、
+// どちらでも宣言されていなければエラーを返します。
+// YouTubeは子ども向けかどうかの宣言を求めており、省略すると誤った扱いになるおそれがあるため、
+// -no-validate でも省略は認めません。
+func applyMadeForKids(meta *videoMetadata, flagValue *bool) error {
+	if meta.MadeForKids == nil {
+		meta.MadeForKids = flagValue
+	}
+	if meta.MadeForKids == nil {
+		return fmt.Errorf("the audience is not declared, pass -made-for-kids=true or -made-for-kids=false (or set made_for_kids in the metadata file)")
+	}
+	return nil
+}
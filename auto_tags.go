@@ -0,0 +1,100 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// -auto-tags は、タイトルと説明に含まれる重要な単語からタグの候補を作ります。
+// 単語は空白と記号で区切り、小文字にしてから次のものを除きます。
+//   - stopwords に含まれる英語の機能語(the、and、with など)
+//   - 3文字未満の単語と、数字だけの単語
+//   - URLの一部とハッシュタグ(#Shorts など)
+// 日本語の文は空白で区切られないため、空白や記号で区切られた語句だけが候補になります。
+// 候補は出現回数の多い順、同じ回数なら最初に現れた順に並べるため、同じ入力からは常に同じタグになります。
+
+// stopwords は、タグの候補から除く英語の機能語です。
+var stopwords = map[string]bool{
+	"a": true, "about": true, "after": true, "all": true, "also": true, "an": true, "and": true,
+	"any": true, "are": true, "as": true, "at": true, "be": true, "been": true, "before": true,
+	"but": true, "by": true, "can": true, "could": true, "did": true, "do": true, "does": true,
+	"for": true, "from": true, "had": true, "has": true, "have": true, "here": true, "how": true, "http": true,
+	"https": true, "into": true, "its": true, "just": true, "more": true, "most": true, "not": true,
+	"now": true, "off": true, "once": true, "only": true, "other": true, "our": true, "out": true,
+	"over": true, "part": true, "see": true, "should": true, "some": true, "such": true, "than": true,
+	"that": true, "the": true, "their": true, "them": true, "then": true, "there": true,
+	"these": true, "they": true, "this": true, "those": true, "through": true, "too": true,
+	"very": true, "video": true, "was": true, "watch": true, "were": true, "what": true,
+	"when": true, "where": true, "which": true, "while": true, "who": true, "why": true,
+	"will": true, "with": true, "would": true, "www": true, "you": true, "your": true,
+}
+
+// minAutoTagLength は、タグの候補にする単語の最小の文字数です。
+const minAutoTagLength = 3
+
+// autoTagCandidates は、タイトルと説明からタグの候補を決まった順に返します。
+func autoTagCandidates(title, description string) []string {
+	count := make(map[string]int)
+	first := make(map[string]int)
+	for _, field := range strings.Fields(title + "\n" + description) {
+		if strings.HasPrefix(field, "#") || strings.Contains(field, "://") {
+			continue
+		}
+		words := strings.FieldsFunc(strings.ToLower(field), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})
+		for _, w := range words {
+			if len([]rune(w)) < minAutoTagLength || stopwords[w] || strings.IndexFunc(w, unicode.IsLetter) < 0 {
+				continue
+			}
+			if _, ok := first[w]; !ok {
+				first[w] = len(first)
+			}
+			count[w]++
+		}
+	}
+	candidates := make([]string, 0, len(count))
+	for w := range count {
+		candidates = append(candidates, w)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if count[a] != count[b] {
+			return count[a] > count[b]
+		}
+		return first[a] < first[b]
+	})
+	return candidates
+}
+
+// withAutoTags は、明示されたタグの後ろにタイトルと説明から作った候補を加えます。
+// 大文字小文字を区別せずに重複を除き、タグ全体が maxTagsLength を超えない範囲で加えます。
+// allowedがnilでない場合は、その語彙に含まれる候補だけを語彙の表記で加えます。
+func withAutoTags(tags []string, title, description string, allowed map[string]string) []string {
+	seen := make(map[string]bool)
+	merged := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		key := strings.ToLower(strings.TrimSpace(tag))
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		merged = append(merged, tag)
+	}
+	for _, candidate := range autoTagCandidates(title, description) {
+		if allowed != nil {
+			v, ok := allowed[candidate]
+			if !ok {
+				continue
+			}
+			candidate = v
+		}
+		if seen[strings.ToLower(candidate)] || tagsLength(append(merged, candidate)) > maxTagsLength {
+			continue
+		}
+		seen[strings.ToLower(candidate)] = true
+		merged = append(merged, candidate)
+	}
+	return merged
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// metadataFields は、CSVの列を割り当てられるメタデータ項目の一覧です。
+var metadataFields = []string{"file", "title", "description", "tags", "privacy", "category"}
+
+// batchDefaults は、バッチモードで -metadata にも各項目にも値がない場合に使う既定値です。
+var batchDefaults = videoMetadata{Privacy: "unlisted", CategoryID: "22"}
+
+// requiredMetadataFields は、バッチモードで必ず列を割り当てる必要がある項目です。
+var requiredMetadataFields = []string{"file", "title"}
+
+// parseColumnMap は、"file=File,title=Title" 形式の文字列を
+// メタデータ項目からCSVヘッダー名への対応表に変換します。
+// 空文字列の場合は、項目名と同じ名前のヘッダーを使用する対応表を返します。
+func parseColumnMap(s string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	if strings.TrimSpace(s) == "" {
+		for _, field := range metadataFields {
+			mapping[field] = field
+		}
+		return mapping, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		field, header, ok := strings.Cut(pair, "=")
+		field = strings.ToLower(strings.TrimSpace(field))
+		header = strings.TrimSpace(header)
+		if !ok || field == "" || header == "" {
+			return nil, fmt.Errorf("malformed entry %q, expected field=Header", pair)
+		}
+		if !isMetadataField(field) {
+			return nil, fmt.Errorf("unknown field %q, must be one of %s", field, strings.Join(metadataFields, ", "))
+		}
+		mapping[field] = header
+	}
+	return mapping, nil
+}
+
+// isMetadataField は、指定された名前がメタデータ項目かどうかを返します。
+func isMetadataField(name string) bool {
+	for _, field := range metadataFields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// readBatchCSV は、CSVファイルを読み込み、対応表に従って各行をメタデータに変換します。
+// 必須項目に列が割り当てられていない場合は、不足している項目をすべて列挙したエラーを返します。
+// 空のセルは空のまま返すため、既定値は呼び出し側で batchDefaults などを重ねて補います。
+func readBatchCSV(path string, mapping map[string]string) ([]videoMetadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header of %s: %v", path, err)
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.TrimSpace(name)] = i
+	}
+
+	// 項目ごとの列番号を解決する
+	columns := make(map[string]int)
+	for field, name := range mapping {
+		i, ok := index[name]
+		if !ok {
+			continue
+		}
+		columns[field] = i
+	}
+	var unmapped []string
+	for _, field := range requiredMetadataFields {
+		if _, ok := columns[field]; ok {
+			continue
+		}
+		if name, ok := mapping[field]; ok {
+			unmapped = append(unmapped, fmt.Sprintf("%s (column %q not found)", field, name))
+		} else {
+			unmapped = append(unmapped, fmt.Sprintf("%s (not in column map)", field))
+		}
+	}
+	if len(unmapped) > 0 {
+		return nil, fmt.Errorf("%s: required fields not mapped to a CSV column: %s",
+			path, strings.Join(unmapped, ", "))
+	}
+
+	var items []videoMetadata
+	for line := 2; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		value := func(field string) string {
+			i, ok := columns[field]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		meta := videoMetadata{
+			File:        value("file"),
+			Title:       value("title"),
+			Description: value("description"),
+			Privacy:     value("privacy"),
+			CategoryID:  value("category"),
+		}
+		if meta.File == "" {
+			return nil, fmt.Errorf("%s:%d: file is empty", path, line)
+		}
+		if tags := value("tags"); tags != "" {
+			meta.Tags = strings.Split(tags, ",")
+		}
+		// どの列もヘッダー名でtitleとdescriptionのテンプレートから参照できる
+		meta.Vars = make(map[string]string, len(header))
+		for i, name := range header {
+			if i < len(record) {
+				meta.Vars[strings.TrimSpace(name)] = strings.TrimSpace(record[i])
+			}
+		}
+		items = append(items, meta)
+	}
+	return items, nil
+}
+
+// readBatchFile は、-batch のファイルを拡張子に従ってCSVまたはJSONとして読み込みます。
+// JSONでは項目名が決まっているため、-column-map は使えません。
+func readBatchFile(path, columnMap string) ([]videoMetadata, error) {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		if columnMap != "" {
+			return nil, fmt.Errorf("-column-map is only supported for CSV batch files")
+		}
+		return readBatchJSON(path)
+	}
+	mapping, err := parseColumnMap(columnMap)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid -column-map: %v", err)
+	}
+	return readBatchCSV(path, mapping)
+}
+
+// readBatchJSON は、-metadata と同じ形式のオブジェクトの配列を読み込み、メタデータに変換します。
+// 知らない項目があれば、書き間違いとしてエラーを返します。
+// CSVと同じく、空の項目は呼び出し側で batchDefaults などを重ねて補います。
+func readBatchJSON(path string) ([]videoMetadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []metadataFile
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&entries); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	items := make([]videoMetadata, 0, len(entries))
+	for i, entry := range entries {
+		if entry.File == "" {
+			return nil, fmt.Errorf("%s: entry %d: file is empty", path, i+1)
+		}
+		if entry.Title == "" {
+			return nil, fmt.Errorf("%s: entry %d (%s): title is empty", path, i+1, entry.File)
+		}
+		items = append(items, entry.metadata())
+	}
+	return items, nil
+}
+
+// toleratedFailuresError は、バッチモードで一部のアップロードが失敗したが、-max-failures に達せずに
+// 最後の項目まで処理したことを表すエラーです。-max-failures は中止するかどうかだけを決め、
+// 失敗が1件でもあれば終了コード1で終了します。失敗したファイルは次回の -sync-dir でも対象にします。
+type toleratedFailuresError struct {
+	failed      int
+	total       int
+	maxFailures int
+}
+
+func (e *toleratedFailuresError) Error() string {
+	if e.maxFailures <= 0 {
+		return fmt.Sprintf("%d of %d uploads failed", e.failed, e.total)
+	}
+	return fmt.Sprintf("%d of %d uploads failed, below -max-failures %d", e.failed, e.total, e.maxFailures)
+}
+
+// batchExitCode は、runBatch が返したエラーに対応する終了コードを返します。
+// アップロードがすべて成功して後続の処理だけが失敗した場合は exitPartialSuccess を、
+// アップロードが1件でも失敗した場合は、すべて失敗した場合と同じく1を返します。
+func batchExitCode(err error) int {
+	var partial *partialSuccessError
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errInterrupted):
+		return exitInterrupted
+	case errors.As(err, &partial):
+		return exitPartialSuccess
+	}
+	return 1
+}
+
+// バッチモードで同時にアップロードする動画の数に関する設定です。
+const (
+	defaultConcurrency = 1
+	// maxConcurrency は、-concurrency に指定できる上限です。
+	// 同時に送るほどクォータを短時間で消費し、レート制限にも当たりやすくなるため、小さく抑えます。
+	maxConcurrency = 4
+)
+
+// validateConcurrency は、-concurrency の値が範囲内かどうかを検証します。
+func validateConcurrency(n int) error {
+	if n < 1 || n > maxConcurrency {
+		return fmt.Errorf("invalid -concurrency %d, must be between 1 and %d", n, maxConcurrency)
+	}
+	return nil
+}
+
+// outputMu は、同時に実行しているアップロードの表示が行の途中で混ざらないよう、標準出力への書き込みを直列化します。
+var outputMu sync.Mutex
+
+// printLocked は、outputMu を取得してから fmt.Printf で表示します。
+func printLocked(format string, a ...interface{}) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	fmt.Printf(format, a...)
+}
+
+// batchSubFailure は、アップロード後の処理が失敗した項目の一覧での位置と、その説明です。
+type batchSubFailure struct {
+	index int
+	text  string
+}
+
+// runBatch は、メタデータの一覧をアップロードし、1件ごとの結果を表示します。
+// concurrency件までを同時にアップロードし、1の場合は順番に処理します。
+// 失敗した項目があっても残りの項目は続行し、失敗件数を *toleratedFailuresError として返します。
+// maxFailures が正の場合は、失敗がその件数に達した時点で残りの項目を始めずに中止します。
+// アップロードがすべて成功し、後続の処理だけが失敗した場合は *partialSuccessError を返します。
+// シグナルで中断した場合は、残りの項目を始めずに errInterrupted を返します。
+// ctxが取り消された場合や期限を過ぎた場合も、残りの項目を始めずにそのエラーを返します。
+// 進行状況は status に記録されます。
+func runBatch(ctx context.Context, u *uploader, items []videoMetadata, status *batchStatus, maxFailures, concurrency int) error {
+	status.begin()
+	var (
+		mu          sync.Mutex
+		subFailures []batchSubFailure
+		aborted     bool
+		stopErr     error
+		wg          sync.WaitGroup
+	)
+	stopped := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return aborted || stopErr != nil
+	}
+	sem := make(chan struct{}, concurrency)
+	notStarted := 0
+	for i, meta := range items {
+		sem <- struct{}{}
+		if stopped() {
+			notStarted = len(items) - i
+			break
+		}
+		wg.Add(1)
+		go func(i int, meta videoMetadata) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			response, err := u.upload(ctx, meta)
+			// responseがあれば動画はアップロード済みで、errはその後の確認の失敗のため、後続の処理と同じく扱う
+			if response == nil && (errors.Is(err, errInterrupted) || (err != nil && ctx.Err() != nil)) {
+				if !errors.Is(err, errInterrupted) {
+					status.fail(err)
+				}
+				printLocked("[%d/%d] %s: %v\n", i+1, len(items), meta.File, redactErr(err))
+				mu.Lock()
+				if stopErr == nil {
+					stopErr = err
+				}
+				mu.Unlock()
+				return
+			}
+			if response == nil && err != nil {
+				status.fail(err)
+				printLocked("[%d/%d] %s: failed: %v\n", i+1, len(items), meta.File, redactErr(err))
+				mu.Lock()
+				if maxFailures > 0 && !aborted && status.report().Failed >= maxFailures {
+					aborted = true
+				}
+				mu.Unlock()
+				return
+			}
+			printLocked("[%d/%d] %s: uploaded, Video ID: %v\n", i+1, len(items), meta.File, response.Id)
+			// 動画はアップロード済みのため、後続の処理が失敗しても成功として数える
+			status.succeeded()
+			if err := errors.Join(err, u.postUpload(ctx, response, meta)); err != nil {
+				printLocked("[%d/%d] %s: %v\n", i+1, len(items), meta.File, redactErr(err))
+				failure := batchSubFailure{index: i, text: fmt.Sprintf("%s (%s)", meta.File, response.Id)}
+				var subErr *subOperationError
+				if errors.As(err, &subErr) {
+					failure.text = fmt.Sprintf("%s (%s): %s", meta.File, response.Id, strings.Join(subErr.names, ", "))
+				}
+				mu.Lock()
+				subFailures = append(subFailures, failure)
+				mu.Unlock()
+			}
+		}(i, meta)
+	}
+	wg.Wait()
+
+	if stopErr != nil {
+		fmt.Printf("Batch stopped, %d items not started\n", notStarted)
+		return stopErr
+	}
+	report := status.report()
+	if aborted {
+		fmt.Printf("Aborting batch after %d failures (-max-failures %d), %d items not started\n",
+			report.Failed, maxFailures, notStarted)
+	}
+	fmt.Printf("Batch finished: %d uploaded, %d failed\n", report.Uploaded, report.Failed)
+	if len(subFailures) > 0 {
+		// 同時に実行した場合は終わった順に記録されるため、一覧の順に並べ直す
+		sort.Slice(subFailures, func(i, j int) bool { return subFailures[i].index < subFailures[j].index })
+		fmt.Println("Uploaded, but post-upload steps failed:")
+		for _, f := range subFailures {
+			fmt.Printf("  %s\n", f.text)
+		}
+	}
+	if aborted {
+		return fmt.Errorf("batch aborted: %d of %d uploads failed, reaching -max-failures %d", report.Failed, len(items), maxFailures)
+	}
+	if report.Failed > 0 {
+		return &toleratedFailuresError{failed: report.Failed, total: len(items), maxFailures: maxFailures}
+	}
+	if len(subFailures) > 0 {
+		return &partialSuccessError{failed: len(subFailures), total: len(items)}
+	}
+	return nil
+}
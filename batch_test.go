@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunBatchExitCodes(t *testing.T) {
+	t.Setenv(configDirEnv, t.TempDir())
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPut {
+			io.Copy(io.Discard, r.Body)
+			w.Write([]byte(`{"id":"video-id","status":{"privacyStatus":"private"}}`))
+			return
+		}
+		var video struct {
+			Snippet struct{ Title string }
+		}
+		json.NewDecoder(r.Body).Decode(&video)
+		if video.Snippet.Title == "bad" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"code":400,"message":"Invalid title","errors":[{"reason":"invalidTitle"}]}}`))
+			return
+		}
+		w.Header().Set("Location", "http://"+r.Host+"/session")
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		titles   []string
+		uploaded int
+		exitCode int
+	}{
+		{"all uploaded", []string{"good", "good"}, 2, 0},
+		{"some failed", []string{"good", "bad", "good"}, 2, 1},
+		{"all failed", []string{"bad", "bad"}, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var items []videoMetadata
+			for i, title := range tt.titles {
+				path := filepath.Join(dir, fmt.Sprintf("%d-%s.mp4", i, title))
+				if err := os.WriteFile(path, []byte("video bytes"), 0600); err != nil {
+					t.Fatal(err)
+				}
+				items = append(items, videoMetadata{File: path, Title: title, Privacy: "private"})
+			}
+			for _, maxFailures := range []int{0, 5} {
+				u := newUploader(srv.Client(), newTestService(t, srv), uploadOptions{MaxAttempts: defaultMaxAttempts, NoProgress: true})
+				status := newBatchStatus(len(items))
+				err := runBatch(context.Background(), u, items, status, maxFailures, 1)
+				var tolerated *toleratedFailuresError
+				if failed := len(items) - tt.uploaded; failed > 0 && !errors.As(err, &tolerated) {
+					t.Errorf("-max-failures %d: runBatch = %v, want a *toleratedFailuresError", maxFailures, err)
+				}
+				if report := status.report(); report.Uploaded != tt.uploaded || report.Failed != len(items)-tt.uploaded {
+					t.Errorf("-max-failures %d: %d uploaded, %d failed, want %d and %d",
+						maxFailures, report.Uploaded, report.Failed, tt.uploaded, len(items)-tt.uploaded)
+				}
+				if code := batchExitCode(err); code != tt.exitCode {
+					t.Errorf("-max-failures %d: exit code %d, want %d", maxFailures, code, tt.exitCode)
+				}
+			}
+		})
+	}
+}
+
+func TestBatchExitCodeForPostUploadFailures(t *testing.T) {
+	if code := batchExitCode(&partialSuccessError{failed: 1, total: 2}); code != exitPartialSuccess {
+		t.Errorf("exit code %d, want %d when only post-upload steps failed", code, exitPartialSuccess)
+	}
+}
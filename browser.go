@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// browserEnv は、ブラウザを開くコマンドを指定する環境変数です。
+// 多くのツールと同じく、":"で区切って複数のコマンドを候補として並べられ、
+// コマンドに"%s"が含まれる場合はそこにURLを埋め込み、含まれない場合は最後の引数として渡します。
+const browserEnv = "BROWSER"
+
+// openURLは指定された場所にブラウザウィンドウを開きます。
+// BROWSER が設定されている場合はそのコマンドを優先し、どれも起動できなければOSごとのコマンドを使います。
+// このコードは元々以下に表示されました：
+//
+//	http://stackoverflow.com/questions/10377243/how-can-i-launch-a-process-that-is-not-a-file-in-go
+func openURL(url string) error {
+	if browsers := os.Getenv(browserEnv); browsers != "" {
+		err := openWithBrowserEnv(browsers, url)
+		if err == nil {
+			return nil
+		}
+		fmt.Printf("Unable to open the URL with $%s (%v), falling back to the default browser\n", browserEnv, err)
+	}
+
+	var err error
+	switch runtime.GOOS {
+	case "linux":
+		if isWSL() {
+			err = openURLFromWSL(url)
+		} else {
+			err = exec.Command("xdg-open", url).Start()
+		}
+	case "windows":
+		err = exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
+	case "darwin":
+		err = exec.Command("open", url).Start()
+	default:
+		err = fmt.Errorf("Cannot open URL %s on this platform", url)
+	}
+	return err
+}
+
+// openWithBrowserEnv は、BROWSER に並んだコマンドを順に試し、最初に起動できたもので url を開きます。
+func openWithBrowserEnv(browsers, url string) error {
+	var lastErr error
+	for _, browser := range strings.Split(browsers, string(os.PathListSeparator)) {
+		args := strings.Fields(browser)
+		if len(args) == 0 {
+			continue
+		}
+		if strings.Contains(browser, "%s") {
+			for i, arg := range args {
+				args[i] = strings.ReplaceAll(arg, "%s", url)
+			}
+		} else {
+			args = append(args, url)
+		}
+		if lastErr = exec.Command(args[0], args[1:]...).Start(); lastErr == nil {
+			return nil
+		}
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("$%s has no command", browserEnv)
+	}
+	return lastErr
+}
+
+// isWSL は、Windows Subsystem for Linux の中で実行されているかどうかを返します。
+// WSLのカーネルのリリース名には"microsoft"が含まれます。
+func isWSL() bool {
+	if os.Getenv("WSL_DISTRO_NAME") != "" {
+		return true
+	}
+	release, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return false
+	}
+	return strings.Contains(strings.ToLower(string(release)), "microsoft")
+}
+
+// openURLFromWSL は、WSLの中からWindows側のブラウザで url を開きます。
+// wslu の wslview があればそれを使い、なければ cmd.exe の start を使います。
+func openURLFromWSL(url string) error {
+	if path, err := exec.LookPath("wslview"); err == nil {
+		return exec.Command(path, url).Start()
+	}
+	// cmd.exe は"&"をコマンドの区切りとして扱うため、クエリ文字列が途切れないようにエスケープする
+	return exec.Command("cmd.exe", "/c", "start", "", strings.ReplaceAll(url, "&", "^&")).Start()
+}
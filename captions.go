@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/youtube/v3"
+
+	"github.com/maguro-alternative/youtube-go/upload"
+)
+
+// アップロード直後の動画は処理が終わるまで字幕を受け付けないことがあるため、その間は待って再試行します。
+const (
+	// captionRetryInterval は、処理中の動画に字幕を追加し直すまでの間隔です。
+	captionRetryInterval = 30 * time.Second
+	// captionMaxWait は、処理中の動画に字幕を追加できるようになるまで待つ時間の上限です。
+	captionMaxWait = 10 * time.Minute
+)
+
+// captionTrack は、-captions で指定された字幕ファイルとその言語です。
+type captionTrack = upload.CaptionTrack
+
+// parseCaptions は、"en:file.srt" 形式の値を字幕の一覧に変換します。
+// ファイルが読めない場合や、同じ言語が複数指定された場合はエラーを返します。
+func parseCaptions(values []string) ([]captionTrack, error) {
+	var tracks []captionTrack
+	seen := make(map[string]bool)
+	for _, v := range values {
+		language, path, ok := strings.Cut(v, ":")
+		language = strings.TrimSpace(language)
+		path = strings.TrimSpace(path)
+		if !ok || language == "" || path == "" {
+			return nil, fmt.Errorf("malformed -captions %q, expected language:file (e.g. en:video.srt)", v)
+		}
+		if seen[strings.ToLower(language)] {
+			return nil, fmt.Errorf("-captions has more than one file for %s", language)
+		}
+		seen[strings.ToLower(language)] = true
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			return nil, fmt.Errorf("caption file %s is a directory", path)
+		}
+		tracks = append(tracks, captionTrack{Language: language, Path: path})
+	}
+	return tracks, nil
+}
+
+// uploadCaption は、srtPathの字幕ファイルをlanguageの字幕として動画に追加します。
+// nameは字幕トラックの名前で、空の場合は名前のないトラックになります。
+// 動画の処理が終わっていないために追加できない場合は、captionMaxWait まで待って再試行します。
+func uploadCaption(service *youtube.Service, videoID, language, name, srtPath string) error {
+	caption := &youtube.Caption{
+		Snippet: &youtube.CaptionSnippet{
+			VideoId:  videoID,
+			Language: language,
+			Name:     name,
+		},
+	}
+	deadline := time.Now().Add(captionMaxWait)
+	for {
+		err := insertCaption(service, caption, srtPath)
+		if err == nil {
+			break
+		}
+		if !isVideoNotReady(err) || time.Now().Add(captionRetryInterval).After(deadline) {
+			return fmt.Errorf("adding %s captions from %s: %w", language, srtPath, withQuotaHint("captions.insert", err))
+		}
+		fmt.Printf("Video %s is still processing, adding %s captions again in %v\n", videoID, language, captionRetryInterval)
+		time.Sleep(captionRetryInterval)
+	}
+	recordResult("captions.insert", videoID)
+	fmt.Printf("Added %s captions from %s\n", language, srtPath)
+	return nil
+}
+
+// insertCaption は、字幕ファイルを開いて1回だけ captions.insert を呼び出します。
+// 再試行のたびにファイルを先頭から送り直せるよう、呼び出しごとに開き直します。
+func insertCaption(service *youtube.Service, caption *youtube.Caption, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = service.Captions.Insert([]string{"snippet"}, caption).Media(f).Do()
+	return err
+}
+
+// isVideoNotReady は、動画の処理が終わっていないために操作できなかったことを表すエラーかどうかを返します。
+// 処理中の動画は、まだ存在しないかのように404や、前提条件を満たさないとして400を返します。
+func isVideoNotReady(err error) bool {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if apiErr.Code == http.StatusNotFound {
+		return true
+	}
+	for _, e := range apiErr.Errors {
+		if e.Reason == "failedPrecondition" || e.Reason == "videoNotFound" {
+			return true
+		}
+	}
+	return false
+}
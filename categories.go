@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// defaultCategoryRegion は、カテゴリの一覧を取得する既定の地域です。
+const defaultCategoryRegion = "US"
+
+// runCategories は、地域で使える動画のカテゴリIDと名前を一覧表示します。
+// 取得した一覧はキャッシュし、-category に名前を指定したときの解決に使います。
+func runCategories(args []string) error {
+	fs := flag.NewFlagSet("categories", flag.ExitOnError)
+	region := fs.String("region", defaultCategoryRegion, "ISO 3166-1 alpha-2 region code to list categories for")
+	addAccountFlag(fs)
+	out := newCommandOutput(fs, "categories")
+	fs.Parse(args)
+	out.start()
+
+	result, err := listCategories(strings.ToUpper(*region))
+	return out.finish(result, err)
+}
+
+// categoryResult は、categories の -json で出力するカテゴリごとの結果です。
+type categoryResult struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	// Assignable は、動画に割り当てられるカテゴリかどうかです。
+	Assignable bool `json:"assignable"`
+}
+
+// listCategories は、regionのカテゴリを表示して返し、割り当てられるものをキャッシュに保存します。
+func listCategories(region string) ([]categoryResult, error) {
+	_, service, err := newService(context.Background(), youtube.YoutubeReadonlyScope)
+	if err != nil {
+		return nil, err
+	}
+	response, err := service.VideoCategories.List([]string{"snippet"}).RegionCode(region).Do()
+	if err != nil {
+		return nil, fmt.Errorf("listing categories for %s: %w", region, err)
+	}
+
+	results := []categoryResult{}
+	assignable := make(map[string]string)
+	for _, category := range response.Items {
+		results = append(results, categoryResult{
+			ID:         category.Id,
+			Title:      category.Snippet.Title,
+			Assignable: category.Snippet.Assignable,
+		})
+		note := ""
+		if category.Snippet.Assignable {
+			assignable[category.Id] = category.Snippet.Title
+		} else {
+			note = "  (not assignable)"
+		}
+		fmt.Printf("%4s  %s%s\n", category.Id, category.Snippet.Title, note)
+	}
+	if err := saveCategoryCache(region, assignable); err != nil {
+		fmt.Printf("Unable to cache categories: %v\n", err)
+	}
+	return results, nil
+}
+
+// categoryCacheFile は、regionのカテゴリの一覧をキャッシュするファイルのパスを返します。
+func categoryCacheFile(region string) (string, error) {
+	return configPath("cache", "categories-"+strings.ToUpper(region)+".json")
+}
+
+// saveCategoryCache は、カテゴリIDから名前への対応表をキャッシュに保存します。
+func saveCategoryCache(region string, categories map[string]string) error {
+	path, err := categoryCacheFile(region)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, func(f *os.File) error {
+		return newFileJSONEncoder(f).Encode(categories)
+	})
+}
+
+// loadCategories は、regionのカテゴリIDから名前への対応表を返します。
+// categories で保存したキャッシュがなければ、組み込みの videoCategories を返します。
+func loadCategories(region string) map[string]string {
+	path, err := categoryCacheFile(region)
+	if err != nil {
+		return videoCategories
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return videoCategories
+	}
+	var categories map[string]string
+	if err := json.Unmarshal(b, &categories); err != nil || len(categories) == 0 {
+		return videoCategories
+	}
+	return categories
+}
+
+// resolveCategory は、-category などに指定されたカテゴリIDまたは名前をカテゴリIDに変換します。
+// 数字だけの値はIDとしてそのまま返します。名前は大文字小文字を区別せず、regionの一覧から探します。
+func resolveCategory(value, region string) (string, error) {
+	if value == "" || strings.Trim(value, "0123456789") == "" {
+		return value, nil
+	}
+	categories := loadCategories(region)
+	for id, name := range categories {
+		if strings.EqualFold(name, value) {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("unknown category %q, run \"categories -region %s\" to list and cache the categories", value, region)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// runChannels は、認証されたアカウントのチャンネルを一覧表示します。
+// ブランドアカウントなど別のチャンネルにアップロードしてしまわないよう、アップロード前の確認に使います。
+func runChannels(args []string) error {
+	fs := flag.NewFlagSet("channels", flag.ExitOnError)
+	addAccountFlag(fs)
+	out := newCommandOutput(fs, "channels")
+	fs.Parse(args)
+	out.start()
+
+	result, err := listChannels()
+	return out.finish(result, err)
+}
+
+// channelResult は、channels の -json で出力するチャンネルごとの結果です。
+type channelResult struct {
+	ChannelID string `json:"channel_id"`
+	Title     string `json:"title"`
+	// Subscribers は登録者数です。チャンネルが登録者数を非公開にしている場合はnilです。
+	Subscribers *uint64 `json:"subscribers"`
+	// UploadsPlaylistID は、チャンネルのアップロード済み動画の再生リストのIDです。
+	UploadsPlaylistID string `json:"uploads_playlist_id"`
+}
+
+// listChannels は、認証されたアカウントのチャンネルを表示して返します。
+func listChannels() ([]channelResult, error) {
+	_, service, err := newService(context.Background(), youtube.YoutubeReadonlyScope)
+	if err != nil {
+		return nil, err
+	}
+	response, err := service.Channels.List([]string{"snippet", "contentDetails", "statistics"}).Mine(true).Do()
+	if err != nil {
+		return nil, fmt.Errorf("listing channels: %w", err)
+	}
+	if len(response.Items) == 0 {
+		return nil, fmt.Errorf("the authenticated user has no channel")
+	}
+
+	results := []channelResult{}
+	for _, channel := range response.Items {
+		result := channelResult{
+			ChannelID: channel.Id,
+			Title:     channel.Snippet.Title,
+		}
+		if channel.ContentDetails != nil && channel.ContentDetails.RelatedPlaylists != nil {
+			result.UploadsPlaylistID = channel.ContentDetails.RelatedPlaylists.Uploads
+		}
+		subscribers := "hidden"
+		if stats := channel.Statistics; stats != nil && !stats.HiddenSubscriberCount {
+			result.Subscribers = &stats.SubscriberCount
+			subscribers = fmt.Sprint(stats.SubscriberCount)
+		}
+		fmt.Printf("%s  %s  (%s subscribers)\n", channel.Id, channel.Snippet.Title, subscribers)
+		results = append(results, result)
+	}
+	return results, nil
+}
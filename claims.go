@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// -wait-for-claims は、アップロード後に指定した時間だけ動画の状態を確認し、
+// 著作権の申し立て(Content IDの申し立て)があったことを示す兆候を報告します。
+//
+// YouTube Data APIは申し立ての内容そのものを返しません。申し立ての詳細を取得するには
+// コンテンツ所有者向けのContent ID APIが必要で、YouTubeパートナーのアカウントでしか使えません。
+// このツールはData APIで見える次の兆候だけを確認します。
+//   - status.uploadStatus が rejected で、rejectionReason が copyright、claim、duplicate のいずれか
+//   - contentDetails.regionRestriction により、一部の地域で再生がブロックされている
+// 兆候がなくても申し立てがないとは限らないため、結果はYouTube Studioで確認してください。
+
+// claimsPollInterval は、申し立ての兆候を確認する間隔です。
+const claimsPollInterval = 30 * time.Second
+
+// claimRejectionReasons は、申し立てや著作権を理由とする拒否の理由です。
+var claimRejectionReasons = map[string]bool{
+	"copyright": true,
+	"claim":     true,
+	"duplicate": true,
+}
+
+// claimIndicators は、動画のリソースから申し立てを示す兆候を取り出します。
+func claimIndicators(video *youtube.Video) []string {
+	var indicators []string
+	if video.Status != nil && video.Status.UploadStatus == "rejected" && claimRejectionReasons[video.Status.RejectionReason] {
+		indicators = append(indicators, fmt.Sprintf("upload rejected: %s", video.Status.RejectionReason))
+	}
+	if video.ContentDetails != nil && video.ContentDetails.RegionRestriction != nil {
+		r := video.ContentDetails.RegionRestriction
+		if len(r.Blocked) > 0 {
+			indicators = append(indicators, fmt.Sprintf("blocked in %s", strings.Join(r.Blocked, ", ")))
+		}
+		if len(r.Allowed) > 0 {
+			indicators = append(indicators, fmt.Sprintf("only viewable in %s", strings.Join(r.Allowed, ", ")))
+		}
+	}
+	return indicators
+}
+
+// waitForClaims は、window の間 claimsPollInterval ごとに動画の状態を確認し、
+// 申し立ての兆候が見つかった時点でそれを表示します。
+// 兆候が見つからなかった場合も、その旨を表示してnilを返します。
+func waitForClaims(service *youtube.Service, videoID string, window time.Duration) error {
+	fmt.Printf("Watching video %s for claim indicators for %v\n", videoID, window)
+	deadline := time.Now().Add(window)
+	for {
+		response, err := service.Videos.List([]string{"status", "contentDetails"}).Id(videoID).Do()
+		if err != nil {
+			return fmt.Errorf("checking video %s for claims: %w", videoID, err)
+		}
+		if len(response.Items) == 0 {
+			return fmt.Errorf("video %s not found while checking for claims", videoID)
+		}
+		if indicators := claimIndicators(response.Items[0]); len(indicators) > 0 {
+			fmt.Printf("Video %s may have a copyright claim:\n", videoID)
+			for _, indicator := range indicators {
+				fmt.Printf("  - %s\n", indicator)
+			}
+			fmt.Println("Check YouTube Studio for the claim details; the Data API does not expose them.")
+			return nil
+		}
+		if time.Now().Add(claimsPollInterval).After(deadline) {
+			break
+		}
+		time.Sleep(claimsPollInterval)
+	}
+	fmt.Printf("No claim indicators for video %s within %v (claims can still appear later)\n", videoID, window)
+	return nil
+}
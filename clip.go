@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// -clip-start と -clip-end による切り出しには、PATH上のffmpegとffprobeが必要です。
+// 切り出しは再エンコードせずにストリームをコピーするため高速ですが、
+// 開始位置は直前のキーフレームに丸められることがあります。
+
+// parseTimestamp は、"1:02:03.5"、"02:03"、"123.5" の形式の時刻を解釈します。
+func parseTimestamp(s string) (time.Duration, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+	var seconds float64
+	for _, part := range parts {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid timestamp %q", s)
+		}
+		seconds = seconds*60 + v
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// probeDuration は、ffprobeを使用して動画ファイルの長さを取得します。
+func probeDuration(path string) (time.Duration, error) {
+	out, err := exec.Command("ffprobe", "-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1", path).Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe %s: %v", path, err)
+	}
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe %s: unexpected duration %q", path, out)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// clipVideo は、ffmpegを使用してpathのstartからendまでを一時ファイルに切り出します。
+// startが空の場合は先頭から、endが空の場合は末尾までを切り出します。
+// 切り出したファイルのパスと、そのファイルを削除する関数を返します。
+func clipVideo(path, start, end string) (string, func(), error) {
+	duration, err := probeDuration(path)
+	if err != nil {
+		return "", nil, err
+	}
+	from, to := time.Duration(0), duration
+	if start != "" {
+		if from, err = parseTimestamp(start); err != nil {
+			return "", nil, err
+		}
+	}
+	if end != "" {
+		if to, err = parseTimestamp(end); err != nil {
+			return "", nil, err
+		}
+	}
+	if from >= to {
+		return "", nil, fmt.Errorf("-clip-start %v must be before -clip-end %v", from, to)
+	}
+	if to > duration {
+		return "", nil, fmt.Errorf("-clip-end %v is beyond the end of %s (%v)", to, path, duration)
+	}
+
+	f, err := os.CreateTemp("", "youtube-go-clip-*"+filepath.Ext(path))
+	if err != nil {
+		return "", nil, err
+	}
+	f.Close()
+	cleanup := func() { os.Remove(f.Name()) }
+
+	cmd := exec.Command("ffmpeg", "-v", "error", "-y",
+		"-ss", formatSeconds(from), "-to", formatSeconds(to),
+		"-i", path, "-c", "copy", f.Name())
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("ffmpeg: %v", err)
+	}
+	fmt.Printf("Clipped %s from %v to %v into %s\n", path, from, to, f.Name())
+	return f.Name(), cleanup, nil
+}
+
+// formatSeconds は、ffmpegに渡すための秒数の文字列を返します。
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
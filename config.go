@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFileName は、毎回のフラグを省くための設定ファイルの名前です。
+// カレントディレクトリ、次に configDir (Linuxでは ~/.config/youtube-go)の順に探し、最初に見つかったものを使います。
+const configFileName = "youtube-go.yaml"
+
+// fileConfig は、設定ファイルの内容です。
+// アップロードの項目は対応するフラグの既定値になり、コマンドラインで指定したフラグが優先します。
+type fileConfig struct {
+	Title       string   `yaml:"title"`
+	Description string   `yaml:"description"`
+	Tags        []string `yaml:"tags"`
+	Privacy     string   `yaml:"privacy"`
+	Category    string   `yaml:"category"`
+	// ClientSecret は、クライアントシークレットのファイルのパスです。
+	// 環境変数 YOUTUBE_CLIENT_SECRET_FILE が設定されていればそちらを優先します。
+	ClientSecret string `yaml:"client_secret"`
+	// OAuthPort は、ウェブサーバーのフローで認証コードを受け取るポートです。
+	// 環境変数 YOUTUBE_OAUTH_PORT が設定されていればそちらを優先します。
+	OAuthPort string `yaml:"oauth_port"`
+}
+
+// findConfigFile は、設定ファイルのパスを返します。見つからない場合は空文字列を返します。
+// 探すだけで書き込まないため、設定ディレクトリは作成しません。
+func findConfigFile() (string, error) {
+	candidates := []string{configFileName}
+	if dir, err := configDirPath(); err == nil {
+		candidates = append(candidates, filepath.Join(dir, configFileName))
+	}
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// loadConfig は、設定ファイルを探して読み込みます。ファイルがない場合は空の設定を返します。
+// 知らない項目があれば、書き間違いとしてエラーを返します。
+func loadConfig() (*fileConfig, error) {
+	path, err := findConfigFile()
+	if err != nil || path == "" {
+		return &fileConfig{}, err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg fileConfig
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	// 空のファイルはEOFになるため、空の設定として扱う
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &cfg, nil
+}
+
+// applyEnv は、設定ファイルのクライアントシークレットとポートを、環境変数が設定されていない場合に限って設定します。
+// どちらもサブコマンドを含めて環境変数から読まれるため、環境変数として渡します。
+func (c *fileConfig) applyEnv() {
+	setEnvDefault := func(key, value string) {
+		if value != "" && os.Getenv(key) == "" {
+			os.Setenv(key, value)
+		}
+	}
+	setEnvDefault(clientSecretFileEnv, c.ClientSecret)
+	setEnvDefault(oauthPortEnv, c.OAuthPort)
+}
+
+// applyFlagDefaults は、設定ファイルのアップロードの項目を fs のフラグの既定値にします。
+// 解析の前に呼び出すことで、コマンドラインで指定したフラグがこの値を上書きします。
+// 既定値として設定するため、バッチモードで単一の動画のフラグを指定したとはみなされません。
+func (c *fileConfig) applyFlagDefaults(fs *flag.FlagSet) error {
+	values := map[string]string{
+		"title":    c.Title,
+		"desc":     c.Description,
+		"tags":     strings.Join(c.Tags, ","),
+		"privacy":  c.Privacy,
+		"category": c.Category,
+	}
+	for name, value := range values {
+		if value == "" {
+			continue
+		}
+		f := fs.Lookup(name)
+		if f == nil {
+			continue
+		}
+		if err := f.Value.Set(value); err != nil {
+			return fmt.Errorf("%s: invalid %s %q: %v", configFileName, name, value, err)
+		}
+		f.DefValue = value
+	}
+	return nil
+}
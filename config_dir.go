@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// configDirEnv は、クレデンシャルやキャッシュを保存するディレクトリを上書きする環境変数です。
+// テストやサンドボックスで、ファイルの読み書きを特定のディレクトリに閉じ込めるために使います。
+const configDirEnv = "YOUTUBE_GO_CONFIG_DIR"
+
+// configDirPath は、このツールがファイルを保存するディレクトリのパスを、ディレクトリを作成せずに返します。
+// YOUTUBE_GO_CONFIG_DIR が設定されていればその値を、そうでなければOSの設定ディレクトリ
+// (Linuxでは ~/.config/youtube-go)を返します。
+func configDirPath() (string, error) {
+	if dir := os.Getenv(configDirEnv); dir != "" {
+		return dir, nil
+	}
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "youtube-go"), nil
+}
+
+// configDir は、configDirPath のディレクトリを返します。ファイルを書き込めるよう、ディレクトリがなければ作成します。
+func configDir() (string, error) {
+	dir, err := configDirPath()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// configPath は、configDir 以下のサブディレクトリsubにあるnameのパスを返します。
+// サブディレクトリがなければ作成します。
+func configPath(sub, name string) (string, error) {
+	base, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(base, sub)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
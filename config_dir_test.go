@@ -0,0 +1,114 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// underDir は、pathがdirの中にあるかどうかを返します。
+func underDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && !strings.HasPrefix(rel, "..")
+}
+
+func TestConfigDirRelocatesFiles(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(configDirEnv, dir)
+	// カレントディレクトリの設定ファイルを拾わないよう、空のディレクトリで実行する
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	t.Run("token cache", func(t *testing.T) {
+		store, err := cacheTokenStore()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !underDir(dir, store.path) {
+			t.Fatalf("token cache %s is outside %s", store.path, dir)
+		}
+		if err := store.Save(&oauth2.Token{AccessToken: "access"}); err != nil {
+			t.Fatal(err)
+		}
+		if tok, err := store.Load(); err != nil || tok.AccessToken != "access" {
+			t.Errorf("Load = %v, %v, want the saved token", tok, err)
+		}
+	})
+
+	t.Run("session state", func(t *testing.T) {
+		video := filepath.Join(t.TempDir(), "video.mp4")
+		if err := os.WriteFile(video, []byte("video bytes"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(video)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stateFile, err := sessionStateFile(video)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !underDir(dir, stateFile) {
+			t.Fatalf("session state %s is outside %s", stateFile, dir)
+		}
+		saved := savedSession{URI: "https://example.com/session", File: video, Size: info.Size(), ModTime: info.ModTime(), Created: time.Now()}
+		if err := saveSession(video, saved); err != nil {
+			t.Fatal(err)
+		}
+		if got := loadSavedSession(video, info); got == nil || got.URI != saved.URI {
+			t.Errorf("loadSavedSession = %+v, want the saved session", got)
+		}
+	})
+
+	t.Run("config file", func(t *testing.T) {
+		path := filepath.Join(dir, configFileName)
+		if err := os.WriteFile(path, []byte("privacy: private\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		found, err := findConfigFile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if found != path {
+			t.Errorf("findConfigFile = %q, want %q", found, path)
+		}
+		cfg, err := loadConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Privacy != "private" {
+			t.Errorf("privacy = %q, want private", cfg.Privacy)
+		}
+	})
+}
+
+func TestFindConfigFileDoesNotCreateConfigDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "youtube-go")
+	t.Setenv(configDirEnv, dir)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	path, err := findConfigFile()
+	if err != nil || path != "" {
+		t.Fatalf("findConfigFile = %q, %v, want no config file", path, err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("findConfigFile created %s: %v", dir, err)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// runCopyMetadata は、動画のメタデータを別の動画にコピーします。
+// -parts で指定した部分だけを -from の動画から読み込んでVideos.Updateで -to の動画に適用し、
+// 指定しなかった部分は -to の動画の値をそのまま残します。
+func runCopyMetadata(args []string) error {
+	fs := flag.NewFlagSet("copy-metadata", flag.ExitOnError)
+	from := fs.String("from", "", "ID of the video to copy the metadata from")
+	to := fs.String("to", "", "ID of the video to apply the metadata to")
+	partsFlag := fs.String("parts", strings.Join(insertableParts, ","), "Comma-separated parts to copy")
+	addAccountFlag(fs)
+	out := newCommandOutput(fs, "copy-metadata")
+	fs.Parse(args)
+	out.start()
+
+	result, err := copyMetadata(*from, *to, *partsFlag)
+	return out.finish(result, err)
+}
+
+// copyMetadataResult は、copy-metadata の -json で出力する結果です。
+type copyMetadataResult struct {
+	From  string   `json:"from"`
+	To    string   `json:"to"`
+	Parts []string `json:"parts"`
+}
+
+// copyMetadata は、fromの動画のpartsFlagで指定した部分をtoの動画に適用します。
+func copyMetadata(from, to, partsFlag string) (*copyMetadataResult, error) {
+	if from == "" || to == "" {
+		return nil, fmt.Errorf("usage: copy-metadata -from <video ID> -to <video ID> [-parts snippet,status,...]")
+	}
+	if from == to {
+		return nil, fmt.Errorf("-from and -to are the same video %s", from)
+	}
+	parts, err := parseParts(partsFlag)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid -parts: %v", err)
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("-parts must name at least one part to copy")
+	}
+
+	_, service, err := newService(context.Background(), youtube.YoutubeScope)
+	if err != nil {
+		return nil, err
+	}
+	source, err := getVideo(service, from, parts)
+	if err != nil {
+		return nil, err
+	}
+	target := &youtube.Video{Id: to}
+	for _, part := range parts {
+		copyVideoPart(target, source, part)
+	}
+	if _, err := service.Videos.Update(parts, target).Do(); err != nil {
+		return nil, fmt.Errorf("updating video %s: %w", to, withQuotaHint("videos.update", err))
+	}
+	recordResult("videos.update", to)
+	fmt.Printf("Copied %s from video %s to video %s\n", strings.Join(parts, ", "), from, to)
+	return &copyMetadataResult{From: from, To: to, Parts: parts}, nil
+}
+
+// getVideo は、指定した部分を含む動画のリソースを取得します。
+func getVideo(service *youtube.Service, id string, parts []string) (*youtube.Video, error) {
+	response, err := service.Videos.List(parts).Id(id).Do()
+	if err != nil {
+		return nil, fmt.Errorf("getting video %s: %w", id, err)
+	}
+	if len(response.Items) == 0 {
+		return nil, fmt.Errorf("video %s not found", id)
+	}
+	return response.Items[0], nil
+}
+
+// copyVideoPart は、srcの動画リソースのうちpartの部分をdstにコピーします。
+// 読み取り専用の項目はVideos.Updateで送れないため、書き込める項目だけをコピーします。
+func copyVideoPart(dst, src *youtube.Video, part string) {
+	switch part {
+	case "snippet":
+		if src.Snippet == nil {
+			return
+		}
+		dst.Snippet = &youtube.VideoSnippet{
+			Title:                src.Snippet.Title,
+			Description:          src.Snippet.Description,
+			Tags:                 src.Snippet.Tags,
+			CategoryId:           src.Snippet.CategoryId,
+			DefaultLanguage:      src.Snippet.DefaultLanguage,
+			DefaultAudioLanguage: src.Snippet.DefaultAudioLanguage,
+		}
+	case "status":
+		if src.Status == nil {
+			return
+		}
+		dst.Status = &youtube.VideoStatus{
+			PrivacyStatus:           src.Status.PrivacyStatus,
+			PublishAt:               src.Status.PublishAt,
+			License:                 src.Status.License,
+			Embeddable:              src.Status.Embeddable,
+			PublicStatsViewable:     src.Status.PublicStatsViewable,
+			SelfDeclaredMadeForKids: src.Status.SelfDeclaredMadeForKids,
+			ForceSendFields:         []string{"Embeddable", "PublicStatsViewable", "SelfDeclaredMadeForKids"},
+		}
+	case "recordingDetails":
+		dst.RecordingDetails = src.RecordingDetails
+	case "localizations":
+		dst.Localizations = src.Localizations
+	}
+}
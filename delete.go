@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// runDelete は、フィルター式に一致するアップロード済みの動画、または引数で指定したIDの動画を削除します。
+// 対象の動画の一覧を先に表示し、-confirm が指定されない限り削除の前に確認を求めます。
+// -dry-run の場合は一覧を表示するだけで削除しません。
+func runDelete(args []string) error {
+	fs := flag.NewFlagSet("delete", flag.ExitOnError)
+	filterExpr := fs.String("filter", "", `Delete uploads matching this filter (e.g. "privacy=private AND title~test")`)
+	dryRun := fs.Bool("dry-run", false, "Only list the videos that would be deleted")
+	confirm := fs.Bool("confirm", false, "Delete without asking for confirmation")
+	addAccountFlag(fs)
+	out := newCommandOutput(fs, "delete")
+	fs.Parse(args)
+	out.start()
+
+	result, err := deleteUploads(*filterExpr, fs.Args(), *dryRun, *confirm)
+	return out.finish(result, err)
+}
+
+// deleteResult は、delete の -json で出力する結果です。
+type deleteResult struct {
+	Matched []string `json:"matched"`
+	Deleted []string `json:"deleted"`
+	Failed  []string `json:"failed,omitempty"`
+	DryRun  bool     `json:"dry_run,omitempty"`
+	Aborted bool     `json:"aborted,omitempty"`
+}
+
+// deleteUploads は、フィルター式に一致するアップロード済みの動画、またはidsの動画を削除し、その結果を返します。
+// 見つからないIDは削除に失敗したものとして数えます。
+func deleteUploads(filterExpr string, ids []string, dryRun, confirm bool) (*deleteResult, error) {
+	if (filterExpr == "") == (len(ids) == 0) {
+		return nil, fmt.Errorf("usage: delete -filter <expression> [-dry-run] [-confirm], or delete [-dry-run] [-confirm] <video ID>...")
+	}
+
+	var filter videoFilter
+	if filterExpr != "" {
+		var err error
+		filter, err = parseFilter(filterExpr)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid -filter: %v", err)
+		}
+	}
+
+	_, service, err := newService(context.Background(), youtube.YoutubeScope)
+	if err != nil {
+		return nil, err
+	}
+	result := &deleteResult{Matched: []string{}, Deleted: []string{}, DryRun: dryRun}
+	var matched []*youtube.Video
+	if filterExpr != "" {
+		matched, err = matchUploads(service, filter, filterExpr)
+	} else {
+		matched, result.Failed, err = findVideos(service, ids)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, video := range matched {
+		result.Matched = append(result.Matched, video.Id)
+	}
+	// 見つからなかったIDも、削除できなかったものとして全体の件数に含める
+	total := len(matched) + len(result.Failed)
+
+	for _, video := range matched {
+		fmt.Printf("  %s  %-9s  %s\n", video.Id, video.Status.PrivacyStatus, video.Snippet.Title)
+	}
+	if dryRun || len(matched) == 0 {
+		if len(result.Failed) > 0 {
+			return result, fmt.Errorf("%d of %d videos not found", len(result.Failed), total)
+		}
+		return result, nil
+	}
+
+	if !confirm {
+		if !isInteractive() {
+			return nil, fmt.Errorf("refusing to delete without confirmation, pass -confirm to delete non-interactively")
+		}
+		fmt.Printf("Permanently delete these %d videos? Type \"delete\" to confirm: ", len(matched))
+		if readAnswer() != "delete" {
+			fmt.Println("Aborted, nothing was deleted")
+			result.Aborted = true
+			return result, nil
+		}
+	}
+
+	for _, video := range matched {
+		if err := service.Videos.Delete(video.Id).Do(); err != nil {
+			result.Failed = append(result.Failed, video.Id)
+			fmt.Printf("%s: failed: %v\n", video.Id, redactErr(withQuotaHint("videos.delete", err)))
+			continue
+		}
+		recordResult("videos.delete", video.Id)
+		result.Deleted = append(result.Deleted, video.Id)
+		fmt.Printf("%s: deleted\n", video.Id)
+	}
+	if len(result.Failed) > 0 {
+		return result, fmt.Errorf("%d of %d deletions failed", len(result.Failed), total)
+	}
+	return result, nil
+}
+
+// matchUploads は、アップロード済みの動画のうちフィルターに一致するものを返します。
+// filterExprは、表示に使うフィルター式の元の文字列です。
+func matchUploads(service *youtube.Service, filter videoFilter, filterExpr string) ([]*youtube.Video, error) {
+	uploads, err := listUploads(service)
+	if err != nil {
+		return nil, err
+	}
+	var matched []*youtube.Video
+	for _, video := range uploads {
+		if filter.match(video) {
+			matched = append(matched, video)
+		}
+	}
+	fmt.Printf("%d of %d uploads match %q:\n", len(matched), len(uploads), filterExpr)
+	return matched, nil
+}
+
+// findVideos は、idsの動画を取得し、見つからなかったIDとともに返します。
+// 削除の前にタイトルを表示して確認できるよう、IDだけで削除せずにリソースを取得します。
+func findVideos(service *youtube.Service, ids []string) ([]*youtube.Video, []string, error) {
+	found := make(map[string]*youtube.Video)
+	// Videos.Listは1回に50件までのIDを受け付ける
+	for start := 0; start < len(ids); start += 50 {
+		end := start + 50
+		if end > len(ids) {
+			end = len(ids)
+		}
+		response, err := service.Videos.List([]string{"snippet", "status"}).Id(ids[start:end]...).Do()
+		if err != nil {
+			return nil, nil, fmt.Errorf("getting videos: %w", err)
+		}
+		for _, video := range response.Items {
+			found[video.Id] = video
+		}
+	}
+	var videos []*youtube.Video
+	var missing []string
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		// 同じIDが重ねて指定されても1回だけ削除する
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		video, ok := found[id]
+		if !ok {
+			fmt.Printf("%s: not found\n", id)
+			missing = append(missing, id)
+			continue
+		}
+		videos = append(videos, video)
+	}
+	fmt.Printf("%d of %d videos found:\n", len(videos), len(seen))
+	return videos, missing, nil
+}
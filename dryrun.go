@@ -0,0 +1,28 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// printDryRun は、-dry-run で送信されるはずの動画リソースをJSONで表示します。
+// -no-validate を指定していても、存在しないファイルはエラーにします。
+func printDryRun(items []videoMetadata, videos []*youtube.Video) error {
+	for i, item := range items {
+		if item.File != stdinFile {
+			if _, err := os.Stat(item.File); err != nil {
+				return err
+			}
+		}
+		b, err := json.MarshalIndent(videos[i], "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s:\n%s\n", item.File, b)
+	}
+	fmt.Printf("Dry run: %d videos not uploaded\n", len(items))
+	return nil
+}
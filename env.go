@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/joho/godotenv"
+)
+
+// loadDotEnv は、カレントディレクトリの.envを読み込み、まだ設定されていない環境変数に設定します。
+// .envがなくても、プロセスの環境変数に値が設定されていれば動くため、エラーにしません。
+// 書式が誤っている場合は、黙って無視せずエラーを返します。
+func loadDotEnv() error {
+	err := godotenv.Load()
+	if err == nil || errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return fmt.Errorf("Error loading .env file: %v", err)
+}
+
+// deprecatedEnvNames は、環境変数の名前から、以前の誤った名前への対応表です。
+// 以前の名前で設定している環境でも動くよう、正しい名前が空の場合に限って読み込みます。
+var deprecatedEnvNames = map[string]string{
+	// 以前は createOAuth2 がこの綴り誤りの名前からアクセストークンを読み込んでいた
+	"YOUTUBE_ACCESS_TOKEN": "YOOUTUBE_ACCESS_TOKEN",
+}
+
+var (
+	deprecatedEnvMu     sync.Mutex
+	deprecatedEnvWarned = make(map[string]bool)
+)
+
+// getenv は、環境変数nameの値を返します。
+// 空で、以前の名前に値が設定されている場合は、非推奨である旨を一度だけ警告してその値を返します。
+func getenv(name string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	old, ok := deprecatedEnvNames[name]
+	if !ok {
+		return ""
+	}
+	value := os.Getenv(old)
+	if value != "" {
+		deprecatedEnvMu.Lock()
+		if !deprecatedEnvWarned[old] {
+			deprecatedEnvWarned[old] = true
+			log.Printf("Warning: %s is deprecated, rename it to %s", old, name)
+		}
+		deprecatedEnvMu.Unlock()
+	}
+	return value
+}
+
+// requireEnv は、namesの環境変数のうち空のものをすべて挙げたエラーを返します。すべて設定されていればnilを返します。
+func requireEnv(names ...string) error {
+	var missing []string
+	for _, name := range names {
+		if getenv(name) == "" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing environment variables: %s (set them in the environment or in .env)", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// validateEnv は、資格情報に必要な環境変数を認証の前にまとめて検証し、足りないものをすべて挙げます。
+// クライアントシークレットのファイルが指定されていなければ、クライアントIDとシークレットが必要です。
+// 環境変数のトークンを使う場合は、createOAuth2 と同じく、アクセストークンがなければリフレッシュトークンが必要です。
+// どちらもないとOAuthのやり取りの途中で分かりにくいエラーになるため、ここで先に検出します。
+func validateEnv() error {
+	if err := loadDotEnv(); err != nil {
+		return err
+	}
+	var required []string
+	if clientSecretFile == "" && os.Getenv(clientSecretFileEnv) == "" {
+		required = append(required, "YOUTUBE_CLIENT_ID", "YOUTUBE_CLIENT_SECRET")
+	}
+	if usesEnvToken() && getenv("YOUTUBE_ACCESS_TOKEN") == "" {
+		required = append(required, "YOUTUBE_REFRESH_TOKEN")
+	}
+	return requireEnv(required...)
+}
+
+// usesEnvToken は、環境変数のトークンを使う設定かどうかを返します。
+// トークンの一部が環境変数に設定されている場合のほか、キャッシュも対話的な認証もできない場合は、
+// 環境変数のトークンが唯一の手段になります。
+func usesEnvToken() bool {
+	if _, ok := tokenStore.(envTokenStore); !ok || account != "" {
+		return false
+	}
+	if getenv("YOUTUBE_ACCESS_TOKEN") != "" || os.Getenv("YOUTUBE_TOKEN_EXPIRY") != "" {
+		return true
+	}
+	if cacheFile, err := tokenCacheFile(); err == nil {
+		if _, err := os.Stat(cacheFile); err == nil {
+			return false
+		}
+	}
+	return authMode == authModePrompt && !isInteractive()
+}
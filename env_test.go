@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestGetTokenReadsAccessTokenEnv(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// リポジトリの.envを読み込まないよう、空のディレクトリで実行する
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{"current name", map[string]string{"YOUTUBE_ACCESS_TOKEN": "current"}, "current"},
+		{"deprecated alias", map[string]string{"YOOUTUBE_ACCESS_TOKEN": "deprecated"}, "deprecated"},
+		{"current name wins", map[string]string{"YOUTUBE_ACCESS_TOKEN": "current", "YOOUTUBE_ACCESS_TOKEN": "deprecated"}, "current"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"YOUTUBE_ACCESS_TOKEN", "YOOUTUBE_ACCESS_TOKEN", "YOUTUBE_REFRESH_TOKEN", "YOUTUBE_TOKEN_EXPIRY"} {
+				t.Setenv(name, "")
+			}
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			tok, err := getToken()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tok.AccessToken != tt.want {
+				t.Errorf("AccessToken = %q, want %q", tok.AccessToken, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateEnvAcceptsAccessTokenWithoutRefreshToken(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	t.Setenv(configDirEnv, t.TempDir())
+	t.Setenv(clientSecretFileEnv, "")
+	t.Setenv("YOUTUBE_CLIENT_ID", "client-id")
+	t.Setenv("YOUTUBE_CLIENT_SECRET", "client-secret")
+	t.Setenv("YOUTUBE_REFRESH_TOKEN", "")
+	t.Setenv("YOOUTUBE_ACCESS_TOKEN", "")
+
+	t.Setenv("YOUTUBE_ACCESS_TOKEN", "access")
+	t.Setenv("YOUTUBE_TOKEN_EXPIRY", "")
+	if err := validateEnv(); err != nil {
+		t.Errorf("validateEnv with only an access token = %v, want nil", err)
+	}
+	if _, err := createOAuth2(); err != nil {
+		t.Errorf("createOAuth2 with only an access token = %v, want nil", err)
+	}
+
+	t.Setenv("YOUTUBE_ACCESS_TOKEN", "")
+	t.Setenv("YOUTUBE_TOKEN_EXPIRY", "2024-05-01T09:00:00Z")
+	if err := validateEnv(); err == nil || !strings.Contains(err.Error(), "YOUTUBE_REFRESH_TOKEN") {
+		t.Errorf("validateEnv without either token = %v, want YOUTUBE_REFRESH_TOKEN reported", err)
+	}
+}
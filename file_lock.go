@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// tokenFileMu は、同じプロセス内でトークンのキャッシュファイルを読み書きする処理を直列化します。
+var tokenFileMu sync.Mutex
+
+// ファイルロックの待機と、異常終了で残ったロックの扱いに関する設定です。
+const (
+	lockRetryInterval = 50 * time.Millisecond
+	lockTimeout       = 10 * time.Second
+	// staleLockAge を過ぎたロックファイルは、異常終了したプロセスが残したものとみなして削除します。
+	// ロックはトークンの書き込みの間しか持たないため、lockTimeout より短くして、待っている間に取り除けるようにします。
+	staleLockAge = 5 * time.Second
+)
+
+// lockFile は、path+".lock" を排他的に作成して、他のプロセスとの間でpathへの書き込みを直列化します。
+// OSごとのロックの仕組みに依存しないよう、O_EXCLで作成できたプロセスがロックを持つものとします。
+// ロックを解放する関数を返します。
+func lockFile(path string) (func(), error) {
+	lockPath := path + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for lock %s", lockPath)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// writeFileAtomic は、同じディレクトリの一時ファイルに書き込んでから名前を変更することで、
+// 読み込む側が書き込み途中の内容を見ないようにpathを置き換えます。
+func writeFileAtomic(path string, write func(f *os.File) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
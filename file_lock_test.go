@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockFileRemovesStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.json")
+	// 異常終了したプロセスが残したロックファイルを、staleLockAge より前の時刻で作成する
+	if err := os.WriteFile(path+".lock", []byte("12345\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * staleLockAge)
+	if err := os.Chtimes(path+".lock", old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	unlock, err := lockFile(path)
+	if err != nil {
+		t.Fatalf("lockFile with a stale lock: %v", err)
+	}
+	unlock()
+	if elapsed := time.Since(start); elapsed >= lockTimeout {
+		t.Errorf("lockFile took %v, want the stale lock to be removed before the %v timeout", elapsed, lockTimeout)
+	}
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("lock file left behind: %v", err)
+	}
+}
+
+func TestStaleLockAgeIsShorterThanLockTimeout(t *testing.T) {
+	// 待っている間に古いロックを取り除けないと、異常終了の後は毎回タイムアウトしてしまう
+	if staleLockAge >= lockTimeout {
+		t.Errorf("staleLockAge %v must be shorter than lockTimeout %v", staleLockAge, lockTimeout)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// filterOperators は、フィルター式で使える演算子です。長いものから順に照合します。
+// = と != は大文字小文字を区別しない一致、~ と !~ は大文字小文字を区別しない部分一致です。
+var filterOperators = []string{"!=", "!~", "=", "~"}
+
+// filterAnd は、フィルター式の条件を区切るANDです。
+var filterAnd = regexp.MustCompile(`(?i)\s+AND\s+`)
+
+// filterCondition は、フィルター式の1つの条件です。
+type filterCondition struct {
+	field string
+	op    string
+	value string
+}
+
+// videoFilter は、すべての条件に一致する動画を選ぶフィルターです。
+type videoFilter []filterCondition
+
+// parseFilter は、"privacy=private AND title~test" 形式のフィルター式を解釈します。
+// 使用できる項目は id、title、description、privacy、category、tags です。
+func parseFilter(s string) (videoFilter, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, fmt.Errorf("empty filter")
+	}
+	var filter videoFilter
+	for _, expr := range filterAnd.Split(strings.TrimSpace(s), -1) {
+		cond, err := parseCondition(expr)
+		if err != nil {
+			return nil, err
+		}
+		filter = append(filter, cond)
+	}
+	return filter, nil
+}
+
+// parseCondition は、"title~test" 形式の条件を1つ解釈します。
+func parseCondition(expr string) (filterCondition, error) {
+	i := strings.IndexAny(expr, "!=~")
+	if i <= 0 {
+		return filterCondition{}, fmt.Errorf("invalid condition %q, expected field<op>value", expr)
+	}
+	for _, op := range filterOperators {
+		if !strings.HasPrefix(expr[i:], op) {
+			continue
+		}
+		cond := filterCondition{
+			field: strings.ToLower(strings.TrimSpace(expr[:i])),
+			op:    op,
+			value: strings.TrimSpace(expr[i+len(op):]),
+		}
+		if videoField(&youtube.Video{}, cond.field) == nil {
+			return filterCondition{}, fmt.Errorf("unknown field %q in condition %q", cond.field, expr)
+		}
+		return cond, nil
+	}
+	return filterCondition{}, fmt.Errorf("invalid operator in condition %q", expr)
+}
+
+// videoField は、フィルターの項目に対応する動画の値を返します。未知の項目の場合はnilを返します。
+func videoField(v *youtube.Video, field string) []string {
+	snippet := v.Snippet
+	if snippet == nil {
+		snippet = &youtube.VideoSnippet{}
+	}
+	status := v.Status
+	if status == nil {
+		status = &youtube.VideoStatus{}
+	}
+	switch field {
+	case "id":
+		return []string{v.Id}
+	case "title":
+		return []string{snippet.Title}
+	case "description":
+		return []string{snippet.Description}
+	case "privacy":
+		return []string{status.PrivacyStatus}
+	case "category":
+		return []string{snippet.CategoryId}
+	case "tags", "tag":
+		return append([]string{}, snippet.Tags...)
+	}
+	return nil
+}
+
+// match は、動画がすべての条件に一致するかどうかを返します。
+// tagsのように複数の値を持つ項目は、いずれかの値が一致すれば条件に一致します。
+func (f videoFilter) match(v *youtube.Video) bool {
+	for _, cond := range f {
+		matched := false
+		for _, value := range videoField(v, cond.field) {
+			value = strings.ToLower(value)
+			want := strings.ToLower(cond.value)
+			switch cond.op {
+			case "=", "!=":
+				matched = matched || value == want
+			case "~", "!~":
+				matched = matched || strings.Contains(value, want)
+			}
+		}
+		if strings.HasPrefix(cond.op, "!") {
+			matched = !matched
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// runGet は、アップロード済みの動画の現在のメタデータと統計を表示します。
+// ブラウザを開かずに、アップロードした内容をスクリプトから確かめるために使います。
+func runGet(args []string) error {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	addAccountFlag(fs)
+	out := newCommandOutput(fs, "get")
+	fs.Parse(args)
+	out.start()
+
+	if fs.NArg() != 1 {
+		return out.finish(nil, fmt.Errorf("usage: get [-json] <video ID>"))
+	}
+	result, err := getVideoInfo(fs.Arg(0))
+	return out.finish(result, err)
+}
+
+// videoInfo は、get の -json で出力する結果です。
+type videoInfo struct {
+	ID          string   `json:"id"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	Privacy     string   `json:"privacy"`
+	// Views と Likes は、統計が返されなかった場合はnilです。
+	Views *uint64 `json:"views"`
+	Likes *uint64 `json:"likes"`
+}
+
+// getVideoInfo は、videoIDの動画のsnippet、statistics、statusを取得して表示し、その内容を返します。
+func getVideoInfo(videoID string) (*videoInfo, error) {
+	_, service, err := newService(context.Background(), youtube.YoutubeReadonlyScope)
+	if err != nil {
+		return nil, err
+	}
+	video, err := getVideo(service, videoID, []string{"snippet", "statistics", "status"})
+	if err != nil {
+		return nil, err
+	}
+
+	info := &videoInfo{ID: video.Id, Tags: []string{}}
+	if video.Snippet != nil {
+		info.Title = video.Snippet.Title
+		info.Description = video.Snippet.Description
+		if len(video.Snippet.Tags) > 0 {
+			info.Tags = video.Snippet.Tags
+		}
+	}
+	if video.Status != nil {
+		info.Privacy = video.Status.PrivacyStatus
+	}
+	views, likes := "unknown", "unknown"
+	if stats := video.Statistics; stats != nil {
+		info.Views = &stats.ViewCount
+		info.Likes = &stats.LikeCount
+		views, likes = fmt.Sprint(stats.ViewCount), fmt.Sprint(stats.LikeCount)
+	}
+
+	fmt.Printf("ID:          %s\n", info.ID)
+	fmt.Printf("Title:       %s\n", info.Title)
+	fmt.Printf("Privacy:     %s\n", info.Privacy)
+	fmt.Printf("Tags:        %s\n", strings.Join(info.Tags, ", "))
+	fmt.Printf("Views:       %s\n", views)
+	fmt.Printf("Likes:       %s\n", likes)
+	fmt.Printf("Description:\n%s\n", info.Description)
+	return info, nil
+}
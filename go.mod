@@ -2,7 +2,11 @@ module github.com/maguro-alternative/youtube-go
 
 go 1.20
 
-require google.golang.org/api v0.162.0
+require (
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
+	google.golang.org/api v0.162.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	cloud.google.com/go/compute v1.23.3 // indirect
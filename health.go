@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// batchStatus は、バッチモードの進行状況を保持します。
+// ヘルスチェック用のサーバーから参照されるため、複数のゴルーチンから安全に使用できます。
+type batchStatus struct {
+	mu         sync.Mutex
+	ready      bool
+	started    time.Time
+	lastUpload time.Time
+	pending    int
+	uploaded   int
+	failed     int
+	lastError  string
+}
+
+// newBatchStatus は、pending件の項目を処理する batchStatus を生成します。
+// 認証などの準備の間はまだ準備中として扱い、begin で処理の開始を記録します。
+func newBatchStatus(pending int) *batchStatus {
+	return &batchStatus{pending: pending}
+}
+
+// begin は、バッチの処理を開始したことを記録します。
+func (s *batchStatus) begin() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ready = true
+	s.started = time.Now()
+}
+
+// isReady は、バッチの処理が始まっているかどうかを返します。
+func (s *batchStatus) isReady() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ready
+}
+
+// succeeded は、1件のアップロードが成功したことを記録します。
+func (s *batchStatus) succeeded() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending--
+	s.uploaded++
+	s.lastUpload = time.Now()
+}
+
+// fail は、1件の処理が失敗したことを記録します。
+func (s *batchStatus) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending--
+	s.failed++
+	s.lastError = redactErr(err)
+}
+
+// statusReport は、/status が返すJSONの形式です。
+type statusReport struct {
+	Ready      bool       `json:"ready"`
+	Started    *time.Time `json:"started,omitempty"`
+	LastUpload *time.Time `json:"last_upload,omitempty"`
+	Pending    int        `json:"pending"`
+	Uploaded   int        `json:"uploaded"`
+	Failed     int        `json:"failed"`
+	LastError  string     `json:"last_error,omitempty"`
+}
+
+// report は、現在の進行状況のスナップショットを返します。
+func (s *batchStatus) report() statusReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := statusReport{
+		Ready:     s.ready,
+		Pending:   s.pending,
+		Uploaded:  s.uploaded,
+		Failed:    s.failed,
+		LastError: s.lastError,
+	}
+	if !s.started.IsZero() {
+		started := s.started
+		r.Started = &started
+	}
+	if !s.lastUpload.IsZero() {
+		lastUpload := s.lastUpload
+		r.LastUpload = &lastUpload
+	}
+	return r
+}
+
+// startHealthServer は、addrでリッスンするヘルスチェック用のサーバーを起動します。
+// /healthz はプロセスが動作している限り200を返し、/readyz は認証などの準備を終えてバッチが始まるまで503を返します。
+// /status は進行状況をJSONで、/metrics はアップロードの統計をPrometheusのテキスト形式で返します。
+// OAuthのコールバックを受けるサーバーとは別のServeMuxを使用します。
+func startHealthServer(addr string, status *batchStatus, metrics *uploadMetrics) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		if !status.isReady() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, "not ready")
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status.report())
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		metrics.writeTo(w)
+	})
+
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+	fmt.Printf("Serving health checks on %v\n", listener.Addr())
+	return server, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHealthServerReportsNotReadyUntilBatchBegins(t *testing.T) {
+	status := newBatchStatus(2)
+	server, err := startHealthServer("127.0.0.1:0", status, newUploadMetrics())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	get := func(path string) int {
+		rec := httptest.NewRecorder()
+		server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+
+	// 認証を待っている間も、プロセスは動作しているが準備はできていない
+	if code := get("/healthz"); code != http.StatusOK {
+		t.Errorf("/healthz before the batch = %d, want 200", code)
+	}
+	if code := get("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz before the batch = %d, want 503", code)
+	}
+	if report := status.report(); report.Ready || report.Started != nil {
+		t.Errorf("status before the batch = %+v, want not ready and not started", report)
+	}
+
+	status.begin()
+	if code := get("/readyz"); code != http.StatusOK {
+		t.Errorf("/readyz after the batch began = %d, want 200", code)
+	}
+	if report := status.report(); !report.Ready || report.Started == nil {
+		t.Errorf("status after the batch began = %+v, want ready with a start time", report)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// -tags-merge に指定できる値です。
+// 後から重ねるメタデータにタグがある場合に、それまでのタグとどう組み合わせるかを決めます。
+const (
+	// tagMergeReplace は、それまでのタグを後のタグで置き換えます。
+	tagMergeReplace = "replace"
+	// tagMergeAppend は、それまでのタグの後ろに後のタグを加えます。
+	tagMergeAppend = "append"
+	// tagMergeUnion は、加えるタグのうち、大文字小文字を区別せずにまだ含まれないものだけを加えます。
+	tagMergeUnion = "union"
+)
+
+// validateTagMerge は、-tags-merge の値が有効かどうかを検証します。
+func validateTagMerge(strategy string) error {
+	switch strategy {
+	case tagMergeReplace, tagMergeAppend, tagMergeUnion:
+		return nil
+	}
+	return fmt.Errorf("invalid -tags-merge %q, must be one of %s, %s, %s",
+		strategy, tagMergeReplace, tagMergeAppend, tagMergeUnion)
+}
+
+// metadataFile は、-metadata で指定するJSONファイルの形式です。
+// JSONの -batch ファイルは、この形式のオブジェクトの配列です。
+// 項目名はバッチモードの -column-map と同じです。
+type metadataFile struct {
+	File        string   `json:"file"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	Privacy     string   `json:"privacy"`
+	Category    string   `json:"category"`
+	// DefaultLanguage は、titleとdescriptionの言語です。localizationsを指定する場合は必須です。
+	DefaultLanguage string                       `json:"default_language"`
+	Localizations   map[string]videoLocalization `json:"localizations"`
+	// MadeForKids は、動画が子ども向けかどうかの宣言です。-made-for-kids より優先します。
+	MadeForKids *bool `json:"made_for_kids"`
+	// RecordingDate は、動画を録画した日時です。RFC 3339の形式で指定し、recordingDetailsとして送ります。
+	RecordingDate string `json:"recording_date"`
+	// Vars は、titleとdescriptionのテンプレートで使う変数です。-var より優先します。
+	Vars map[string]string `json:"vars"`
+}
+
+// loadMetadataFile は、-metadata で指定されたJSONファイルを読み込みます。
+// 知らない項目があれば、書き間違いとしてエラーを返します。
+func loadMetadataFile(path string) (videoMetadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return videoMetadata{}, err
+	}
+	defer f.Close()
+	var mf metadataFile
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&mf); err != nil {
+		return videoMetadata{}, fmt.Errorf("%s: %v", path, err)
+	}
+	return mf.metadata(), nil
+}
+
+// metadata は、ファイルの内容を videoMetadata に変換します。
+func (mf metadataFile) metadata() videoMetadata {
+	return videoMetadata{
+		File:        mf.File,
+		Title:       mf.Title,
+		Description: mf.Description,
+		Tags:        mf.Tags,
+		Privacy:     mf.Privacy,
+		CategoryID:  mf.Category,
+
+		DefaultLanguage: mf.DefaultLanguage,
+		Localizations:   mf.Localizations,
+		MadeForKids:     mf.MadeForKids,
+		RecordingDate:   mf.RecordingDate,
+		Vars:            mf.Vars,
+	}
+}
+
+// overlayMetadata は、baseの上にtopを重ねたメタデータを返します。
+// topで空でない項目はbaseの値を上書きし、タグは strategy に従って組み合わせます。
+func overlayMetadata(base, top videoMetadata, strategy string) videoMetadata {
+	merged := base
+	if top.File != "" {
+		merged.File = top.File
+	}
+	if top.Title != "" {
+		merged.Title = top.Title
+	}
+	if top.Description != "" {
+		merged.Description = top.Description
+	}
+	if top.Privacy != "" {
+		merged.Privacy = top.Privacy
+	}
+	if top.CategoryID != "" {
+		merged.CategoryID = top.CategoryID
+	}
+	if top.Thumbnail != "" {
+		merged.Thumbnail = top.Thumbnail
+	}
+	if !top.PublishAt.IsZero() {
+		merged.PublishAt = top.PublishAt
+	}
+	if top.MadeForKids != nil {
+		merged.MadeForKids = top.MadeForKids
+	}
+	if top.DefaultLanguage != "" {
+		merged.DefaultLanguage = top.DefaultLanguage
+	}
+	if top.RecordingDate != "" {
+		merged.RecordingDate = top.RecordingDate
+	}
+	// ローカライズは言語ごとに重ね、同じ言語はtopの値で置き換える
+	if len(top.Localizations) > 0 {
+		localizations := make(map[string]videoLocalization, len(base.Localizations)+len(top.Localizations))
+		for language, l := range base.Localizations {
+			localizations[language] = l
+		}
+		for language, l := range top.Localizations {
+			localizations[language] = l
+		}
+		merged.Localizations = localizations
+	}
+	// テンプレートの変数もローカライズと同じく名前ごとに重ねる
+	if len(top.Vars) > 0 {
+		vars := make(map[string]string, len(base.Vars)+len(top.Vars))
+		for key, value := range base.Vars {
+			vars[key] = value
+		}
+		for key, value := range top.Vars {
+			vars[key] = value
+		}
+		merged.Vars = vars
+	}
+	merged.Tags = mergeTags(base.Tags, top.Tags, strategy)
+	return merged
+}
+
+// mergeTags は、baseのタグにtopのタグを strategy に従って組み合わせます。
+// topが空の場合はbaseをそのまま返します。
+func mergeTags(base, top []string, strategy string) []string {
+	if len(top) == 0 {
+		return base
+	}
+	switch strategy {
+	case tagMergeAppend:
+		return append(append([]string(nil), base...), top...)
+	case tagMergeUnion:
+		merged := append([]string(nil), base...)
+		seen := make(map[string]bool, len(base))
+		for _, tag := range base {
+			seen[strings.ToLower(strings.TrimSpace(tag))] = true
+		}
+		for _, tag := range top {
+			key := strings.ToLower(strings.TrimSpace(tag))
+			if !seen[key] {
+				seen[key] = true
+				merged = append(merged, tag)
+			}
+		}
+		return merged
+	}
+	return top
+}
+
+// loadMetadataLayers は、-metadata で指定されたファイルを左から順に重ねたメタデータを返します。
+// 後に指定したファイルほど優先されます。
+func loadMetadataLayers(paths []string, strategy string) (videoMetadata, error) {
+	var merged videoMetadata
+	for _, path := range paths {
+		layer, err := loadMetadataFile(path)
+		if err != nil {
+			return videoMetadata{}, err
+		}
+		merged = overlayMetadata(merged, layer, strategy)
+	}
+	return merged, nil
+}
+
+// stringList は、複数回指定できるフラグの値です。
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// uploadDurationBuckets は、upload_duration_seconds のヒストグラムの上限値(秒)です。
+// 動画のアップロードは数秒から数十分かかるため、広い範囲を取っています。
+var uploadDurationBuckets = []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600}
+
+// uploadMetrics は、アップロードに関する統計をPrometheusのテキスト形式で公開するためのレジストリです。
+// アップロード処理に渡して使用するため、テストでは独自のレジストリを渡してカウンターの値を確認できます。
+type uploadMetrics struct {
+	mu             sync.Mutex
+	uploads        int64
+	uploadBytes    int64
+	durationCounts []int64
+	durationSum    float64
+	durationCount  int64
+	errors         map[string]int64
+	quotaExceeded  int64
+	retries        int64
+}
+
+// newUploadMetrics は、すべての値が0の uploadMetrics を生成します。
+func newUploadMetrics() *uploadMetrics {
+	return &uploadMetrics{
+		durationCounts: make([]int64, len(uploadDurationBuckets)),
+		errors:         make(map[string]int64),
+	}
+}
+
+// observeUpload は、成功したアップロードのバイト数と所要時間を記録します。
+func (m *uploadMetrics) observeUpload(bytes int64, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.uploads++
+	m.uploadBytes += bytes
+	seconds := d.Seconds()
+	for i, le := range uploadDurationBuckets {
+		if seconds <= le {
+			m.durationCounts[i]++
+		}
+	}
+	m.durationSum += seconds
+	m.durationCount++
+}
+
+// observeError は、失敗したAPI呼び出しを理由ごとに記録します。
+// 理由がquotaExceededまたはdailyLimitExceededの場合は quota_exceeded_total も加算します。
+func (m *uploadMetrics) observeError(err error) {
+	if m == nil || err == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.countError(err)
+}
+
+// observeRetry は、再試行することになった失敗を理由ごとに記録し、upload_retries_total を加算します。
+// 再試行を諦めた最後の失敗は、呼び出し元が observeError で記録します。
+func (m *uploadMetrics) observeRetry(err error) {
+	if m == nil || err == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.countError(err)
+	m.retries++
+}
+
+// countError は、errを理由ごとのカウンターに加算します。m.muをロックしてから呼び出します。
+func (m *uploadMetrics) countError(err error) {
+	reason := errorReason(err)
+	m.errors[reason]++
+	if reason == "quotaExceeded" || reason == "dailyLimitExceeded" {
+		m.quotaExceeded++
+	}
+}
+
+// errorReason は、エラーをメトリクスのラベルに使う短い理由に変換します。
+// APIのエラーであればreasonを、reasonがなければHTTPステータスを使用します。
+func errorReason(err error) string {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return "other"
+	}
+	for _, item := range apiErr.Errors {
+		if item.Reason != "" {
+			return item.Reason
+		}
+	}
+	return "http_" + strconv.Itoa(apiErr.Code)
+}
+
+// writeTo は、レジストリの内容をPrometheusのテキスト形式でwに書き込みます。
+func (m *uploadMetrics) writeTo(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP uploads_total Number of videos uploaded successfully.")
+	fmt.Fprintln(w, "# TYPE uploads_total counter")
+	fmt.Fprintf(w, "uploads_total %d\n", m.uploads)
+
+	fmt.Fprintln(w, "# HELP upload_bytes_total Number of video bytes uploaded successfully.")
+	fmt.Fprintln(w, "# TYPE upload_bytes_total counter")
+	fmt.Fprintf(w, "upload_bytes_total %d\n", m.uploadBytes)
+
+	fmt.Fprintln(w, "# HELP upload_duration_seconds Time taken to upload a video.")
+	fmt.Fprintln(w, "# TYPE upload_duration_seconds histogram")
+	for i, le := range uploadDurationBuckets {
+		fmt.Fprintf(w, "upload_duration_seconds_bucket{le=\"%s\"} %d\n",
+			strconv.FormatFloat(le, 'g', -1, 64), m.durationCounts[i])
+	}
+	fmt.Fprintf(w, "upload_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.durationCount)
+	fmt.Fprintf(w, "upload_duration_seconds_sum %s\n", strconv.FormatFloat(m.durationSum, 'g', -1, 64))
+	fmt.Fprintf(w, "upload_duration_seconds_count %d\n", m.durationCount)
+
+	fmt.Fprintln(w, "# HELP upload_errors_total Number of failed API calls by reason.")
+	fmt.Fprintln(w, "# TYPE upload_errors_total counter")
+	reasons := make([]string, 0, len(m.errors))
+	for reason := range m.errors {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		fmt.Fprintf(w, "upload_errors_total{reason=%q} %d\n", reason, m.errors[reason])
+	}
+
+	fmt.Fprintln(w, "# HELP quota_exceeded_total Number of API calls rejected for exceeding the quota.")
+	fmt.Fprintln(w, "# TYPE quota_exceeded_total counter")
+	fmt.Fprintf(w, "quota_exceeded_total %d\n", m.quotaExceeded)
+
+	fmt.Fprintln(w, "# HELP upload_retries_total Number of failed API calls that were retried.")
+	fmt.Fprintln(w, "# TYPE upload_retries_total counter")
+	fmt.Fprintf(w, "upload_retries_total %d\n", m.retries)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUploaderRecordsMetrics(t *testing.T) {
+	defer func(b time.Duration) { retryBackoff = b }(retryBackoff)
+	retryBackoff = time.Millisecond
+	// 結果ログをユーザーの設定ディレクトリに書き込まないようにする
+	t.Setenv(configDirEnv, t.TempDir())
+
+	var (
+		mu       sync.Mutex
+		sessions int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPut {
+			io.ReadAll(r.Body)
+			w.Write([]byte(`{"id":"video-id","status":{"privacyStatus":"private"}}`))
+			return
+		}
+		sessions++
+		switch sessions {
+		case 1:
+			// 1回目のアップロード: 一時的なエラーの後に成功する
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Location", "http://"+r.Host+"/session")
+		case 3:
+			// 2回目以降のアップロード: 割り当ての超過で失敗する
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":{"code":403,"message":"quota","errors":[{"reason":"quotaExceeded"}]}}`))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":{"code":403,"message":"daily limit","errors":[{"reason":"dailyLimitExceeded"}]}}`))
+		}
+	}))
+	defer srv.Close()
+
+	metrics := newUploadMetrics()
+	u := newUploader(srv.Client(), newTestService(t, srv), uploadOptions{MaxAttempts: defaultMaxAttempts, NoProgress: true, Metrics: metrics})
+	meta := videoMetadata{File: "video.mp4", Title: "title", Privacy: "private"}
+	data := []byte("video bytes")
+	if _, err := u.uploadReader(context.Background(), meta, bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatalf("first upload = %v, want success", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := u.uploadReader(context.Background(), meta, bytes.NewReader(data), int64(len(data))); err == nil {
+			t.Fatal("upload over the quota succeeded")
+		}
+	}
+
+	var out bytes.Buffer
+	metrics.writeTo(&out)
+	for _, want := range []string{
+		"uploads_total 1\n",
+		fmt.Sprintf("upload_bytes_total %d\n", len(data)),
+		"upload_duration_seconds_count 1\n",
+		`upload_errors_total{reason="http_503"} 1` + "\n",
+		`upload_errors_total{reason="quotaExceeded"} 1` + "\n",
+		`upload_errors_total{reason="dailyLimitExceeded"} 1` + "\n",
+		"quota_exceeded_total 2\n",
+		"upload_retries_total 1\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("metrics do not contain %q:\n%s", want, out.String())
+		}
+	}
+}
@@ -1,18 +1,24 @@
 package main
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html/template"
 	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
-	"os/exec"
 	"os/user"
 	"path/filepath"
-	"runtime"
+	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/net/context"
 	"golang.org/x/oauth2"
@@ -35,15 +41,117 @@ import (
 //      リスナーはその後、URL内の認証コードをキャプチャし、このスクリプトに返します。
 
 // * launchWebServer = false
-//   1. インストール済みアプリケーション向けのOAuth2資格情報を使用します。
-//      (OAuth2クライアントIDのアプリケーションタイプを選択する際に、「その他」を選択します。)
-//   2. リダイレクトURIを次のように設定します：
-//      config.RedirectURL = "urn:ietf:wg:oauth:2.0:oob"
-//   3. スクリプトを実行する際に、認証フローを完了します。その後、ブラウザから
-//      認証コードをコピーし、コマンドラインに入力します。
+//   1. デスクトップアプリ向けのOAuth2資格情報を使用します。
+//   2. リダイレクトURIには、ウェブサーバーのフローと同じループバックアドレスを使います。
+//      以前のurn:ietf:wg:oauth:2.0:oobはGoogleに廃止され、認証サーバーに拒否されます。
+//   3. スクリプトを実行すると、認証URLが表示されます。別の端末のブラウザで開いてもかまいません。
+//      同じマシンやSSHのポート転送でリダイレクトが届けば、そのまま認証コードを受け取ります。
+//      届かない場合は、ブラウザのアドレスバーに表示されたリダイレクト先のURLをコピーし、
+//      コマンドラインに入力します。
 
 const launchWebServer = false
 
+// 認証フローの種類です。-auth で選びます。
+const (
+	authModeWeb    = "web"
+	authModePrompt = "prompt"
+	authModeDevice = "device"
+)
+
+// authMode は、トークンがない場合に使う認証フローです。既定では launchWebServer の設定に従います。
+var authMode = defaultAuthMode()
+
+// defaultAuthMode は、launchWebServer の設定に対応する認証フローを返します。
+func defaultAuthMode() string {
+	if launchWebServer {
+		return authModeWeb
+	}
+	return authModePrompt
+}
+
+// defaultAuthTimeout は、ウェブサーバーのフローで認証の完了を待つ既定の時間です。
+const defaultAuthTimeout = 5 * time.Minute
+
+// authTimeout は、ウェブサーバーのフローで認証の完了を待つ時間です。0の場合は無期限に待ちます。
+// ブラウザのタブを閉じ忘れても、自動化したスクリプトが止まったままにならないようにします。
+var authTimeout = defaultAuthTimeout
+
+// validateAuthMode は、-auth の値が認証フローの種類かどうかを検証します。
+func validateAuthMode(mode string) error {
+	switch mode {
+	case authModeWeb, authModePrompt, authModeDevice:
+		return nil
+	}
+	return fmt.Errorf("invalid -auth %q, must be one of %s, %s, %s", mode, authModeWeb, authModePrompt, authModeDevice)
+}
+
+// 認証フローごとのリダイレクトURIです。
+// クライアントシークレットのredirect_urisと config.RedirectURL には、同じ値を設定する必要があります。
+// 一致しない場合、Googleの認証サーバーはredirect_uri_mismatchで拒否します。
+const (
+	// oauthPortEnv は、ウェブサーバーのフローで認証コードを受け取るポートを指定する環境変数です。
+	oauthPortEnv = "YOUTUBE_OAUTH_PORT"
+	// defaultOAuthPort は、oauthPortEnv が設定されていない場合のポートです。
+	defaultOAuthPort = "8090"
+)
+
+// webListenAddr は、ウェブサーバーのフローで認証コードを受け取るアドレスを返します。
+func webListenAddr() string {
+	port := os.Getenv(oauthPortEnv)
+	if port == "" {
+		port = defaultOAuthPort
+	}
+	return net.JoinHostPort("localhost", port)
+}
+
+// redirectURL は、認証フローのリダイレクトURIを返します。どちらのフローもループバックアドレスを使います。
+// ポートを使えず別のポートで待ち受けた場合は、getTokenFromWeb と getTokenFromPrompt が置き換えます。
+func redirectURL() string {
+	return "http://" + webListenAddr()
+}
+
+// successPage は、認証が完了したときにブラウザに表示するHTMLのテンプレートです。
+// 既定では defaultSuccessPage を使い、-success-html で置き換えられます。
+// テンプレートには認証コードが .Code として渡されます。
+var successPage = defaultSuccessPage
+
+// defaultSuccessPage は、認証が完了したことを伝え、ターミナルに戻るよう案内するページです。
+// 認証コードは画面に残さないよう表示しません。
+var defaultSuccessPage = template.Must(template.New("success").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>youtube-go: authorized</title>
+<style>body{font-family:sans-serif;max-width:32em;margin:4em auto;color:#222}h1{color:#188038}</style></head>
+<body>
+<h1>Authorization complete</h1>
+<p>youtube-go received the authorization. Return to the terminal to continue; you can close this window.</p>
+</body>
+</html>
+`))
+
+// authErrorPage は、認証のリダイレクトを受け付けなかった理由を表示するページです。
+// テンプレートには理由が .Message として渡されます。
+var authErrorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>youtube-go: authorization failed</title>
+<style>body{font-family:sans-serif;max-width:32em;margin:4em auto;color:#222}h1{color:#d93025}</style></head>
+<body>
+<h1>Authorization failed</h1>
+<p>{{.Message}}</p>
+<p>Return to the terminal and run the command again to retry.</p>
+</body>
+</html>
+`))
+
+// loadSuccessPage は、-success-html で指定されたテンプレートファイルを読み込みます。
+func loadSuccessPage(path string) error {
+	tmpl, err := template.ParseFiles(path)
+	if err != nil {
+		return err
+	}
+	successPage = tmpl
+	return nil
+}
+
 const missingClientSecretsMessage = `
 Please configure OAuth 2.0
 To make this sample run, you need to populate the client_secrets.json file
@@ -55,170 +163,568 @@ For more information about the client_secrets.json file format, please visit:
 https://developers.google.com/api-client-library/python/guide/aaa_client_secrets
 `
 
-// getClient は、コンテキストとコンフィグを使用してトークンを取得します。
-// 次にクライアントを生成します。生成されたクライアントを返します。
-func getClient(scope string) *http.Client {
-	ctx := context.Background()
+// clientSecretFileEnv は、クライアントシークレットのJSONファイルのパスを指定する環境変数です。
+const clientSecretFileEnv = "YOUTUBE_CLIENT_SECRET_FILE"
 
-	b, err := ioutil.ReadFile("client_secret.json")
-	if err != nil {
-		log.Fatalf("Unable to read client secret file: %v", err)
+// clientSecretFile は、-client-secret で指定されたクライアントシークレットのファイルのパスです。
+// 空の場合は clientSecretFileEnv を、それも空の場合は.envまたは環境変数から組み立てます。
+var clientSecretFile string
+
+// readClientSecret は、newService と TokenSource が使うクライアントシークレットのJSONを返します。
+// pathが空の場合は clientSecretFileEnv のファイルを、それも空の場合は.envまたは環境変数から組み立てたものを使います。
+func readClientSecret(path string) ([]byte, error) {
+	if path == "" {
+		path = os.Getenv(clientSecretFileEnv)
+	}
+	if path == "" {
+		return createClinetSecret()
 	}
+	return readClientSecretFile(path)
+}
 
-	// スコープを変更する場合は、以前に保存した認証情報を削除してください。
-	// at ~/.credentials/youtube-go.json
-	config, err := google.ConfigFromJSON(b, scope)
+// readClientSecretFile は、クライアントシークレットのファイルを読み込みます。
+// ファイルがない場合は、作成方法を説明するエラーを返します。
+func readClientSecretFile(path string) ([]byte, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		abs, _ := filepath.Abs(path)
+		return nil, fmt.Errorf("client secret file not found: %v\n"+missingClientSecretsMessage, err, abs)
+	}
 	if err != nil {
-		log.Fatalf("Unable to parse client secret file to config: %v", err)
+		return nil, fmt.Errorf("Unable to read client secret file: %v", err)
 	}
+	return b, nil
+}
 
-	// ウェブアプリにはこのようなリダイレクトURIを使います。
-	// リダイレクト URI は、OAuth2 認証情報に対して有効なものでなければなりません。
-	config.RedirectURL = "http://localhost:8090"
-	// oauth2.goでlaunchWebServer=falseの場合、以下のリダイレクトURIを使用する。
-	// config.RedirectURL = "urn:ietf:wg:oauth:2.0:oob"
+// authorize は、authMode の設定に従ってウェブサーバー、プロンプトまたはデバイスの認証フローを行います。
+// 取得したトークンを返します。
+func authorize(ctx context.Context, config *oauth2.Config) (*oauth2.Token, error) {
+	switch authMode {
+	case authModeWeb:
+		fmt.Println("Trying to get token from web")
+		return getTokenFromWeb(ctx, config)
+	case authModeDevice:
+		fmt.Println("Trying to get token with the device flow")
+		return getTokenFromDevice(ctx, config)
+	}
+	fmt.Println("Trying to get token from prompt")
+	return getTokenFromPrompt(ctx, config)
+}
+
+// TokenStore は、トークンの読み込みと保存を行います。
+type TokenStore interface {
+	// Load は、保存されたトークンを返します。保存されていない場合はエラーを返します。
+	Load() (*oauth2.Token, error)
+	// Save は、トークンを保存します。
+	Save(*oauth2.Token) error
+}
 
+// tokenDeleter は、保存されたトークンを削除できる TokenStore です。
+// リフレッシュトークンが無効になった場合に、使えないトークンを次回に読み込まないよう削除します。
+type tokenDeleter interface {
+	Delete() error
+}
+
+// ErrReauthRequired は、保存されたリフレッシュトークンが取り消されたか期限が切れたため、
+// 認証し直す必要があることを表すエラーです。errors.Is で判定できます。
+var ErrReauthRequired = errors.New("re-authorization required")
+
+// isInvalidGrant は、トークンエンドポイントがリフレッシュトークンを invalid_grant で拒否したかどうかを返します。
+func isInvalidGrant(err error) bool {
+	var rErr *oauth2.RetrieveError
+	return errors.As(err, &rErr) && rErr.ErrorCode == "invalid_grant"
+}
+
+// fileTokenStore は、トークンをJSONファイルに保存する TokenStore です。
+type fileTokenStore struct {
+	path string
+}
+
+// cacheTokenStore は、tokenCacheFile のキャッシュファイルに保存する fileTokenStore を返します。
+func cacheTokenStore() (fileTokenStore, error) {
 	cacheFile, err := tokenCacheFile()
 	if err != nil {
-		log.Fatalf("Unable to get path to cached credential file. %v", err)
+		return fileTokenStore{}, err
 	}
-	tok, err := tokenFromFile(cacheFile)
+	return fileTokenStore{path: cacheFile}, nil
+}
+
+func (s fileTokenStore) Load() (*oauth2.Token, error) {
+	return tokenFromFile(s.path)
+}
+
+func (s fileTokenStore) Save(tok *oauth2.Token) error {
+	return saveToken(s.path, tok)
+}
+
+func (s fileTokenStore) Delete() error {
+	return removeTokenFile(s.path)
+}
+
+// TokenSource は、storeからトークンを読み込み、自動的に更新されるトークンソースを返します。
+// storeにトークンがない場合は認証フローを行い、取得したトークンをstoreに保存します。
+// 保存されたトークンにscopesの一部が許可されていない場合は、以前に許可されたスコープも含めて認証し直し、
+// 別のコマンドのために許可されていたスコープを失わないようにします。
+// 有効期限が切れたトークンはリフレッシュトークンで更新し、更新したトークンをstoreに保存します。
+// クライアントシークレットは readClientSecret の規則でsecretFileから読み込みます。
+// YouTubeのサービスとは独立しているため、他のGoogle APIのクライアントでも使用できます。
+func TokenSource(ctx context.Context, secretFile string, scopes []string, store TokenStore) (oauth2.TokenSource, error) {
+	b, err := readClientSecret(secretFile)
 	if err != nil {
-		authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
-		if launchWebServer {
-			fmt.Println("Trying to get token from web")
-			tok, err = getTokenFromWeb(config, authURL)
-		} else {
-			fmt.Println("Trying to get token from prompt")
-			tok, err = getTokenFromPrompt(config, authURL)
+		return nil, fmt.Errorf("Unable to read client secret: %v", err)
+	}
+	config, err := google.ConfigFromJSON(b, scopes...)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse client secret to config: %v", err)
+	}
+	config.RedirectURL = redirectURL()
+
+	tok, err := store.Load()
+	reauth := err != nil
+	if !reauth {
+		// スコープが記録されていないトークンは、許可された範囲が分からないためそのまま使う
+		if granted := tokenScopes(tok); len(granted) > 0 {
+			if missing := missingScopes(granted, scopes); len(missing) > 0 {
+				fmt.Printf("The saved token does not grant %s, authorizing again\n", strings.Join(missing, " "))
+				config.Scopes = unionScopes(granted, scopes)
+				reauth = true
+			}
+		}
+	}
+	if reauth {
+		tok, err = authorize(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+		// 認可サーバーがscopeを返さなかった場合は、要求したスコープが許可されたものとして記録する
+		if len(tokenScopes(tok)) == 0 {
+			tok = withScopes(tok, config.Scopes)
 		}
-		if err == nil {
-			saveToken(cacheFile, tok)
+		if err := store.Save(tok); err != nil {
+			return nil, fmt.Errorf("Unable to save token: %v", err)
 		}
 	}
-	return config.Client(ctx, tok)
+	// 期限が切れるまでは同じトークンを使い、更新したトークンだけをstoreに書き戻す
+	return oauth2.ReuseTokenSource(tok, &savingTokenSource{src: config.TokenSource(ctx, tok), store: store, last: tok}), nil
 }
 
-// startWebServerは、http://localhost:8080でリッスンするウェブサーバーを起動します。
-// ウェブサーバーは、3段階の認証フローでのOAuthコードを待機します。
-func startWebServer() (codeCh chan string, err error) {
-	listener, err := net.Listen("tcp", "localhost:8090")
+// savingTokenSource は、srcが更新したトークンをstoreに保存する oauth2.TokenSource です。
+type savingTokenSource struct {
+	src   oauth2.TokenSource
+	store TokenStore
+
+	mu   sync.Mutex
+	last *oauth2.Token
+}
+
+func (s *savingTokenSource) Token() (*oauth2.Token, error) {
+	tok, err := s.src.Token()
+	if isInvalidGrant(err) {
+		return nil, s.reauthRequired(err)
+	}
 	if err != nil {
 		return nil, err
 	}
-	codeCh = make(chan string)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if tok.AccessToken == s.last.AccessToken {
+		return tok, nil
+	}
+	// 更新の応答にscopeが含まれない場合は、以前のトークンのスコープを引き継ぐ
+	if len(tokenScopes(tok)) == 0 {
+		tok = withScopes(tok, tokenScopes(s.last))
+	}
+	s.last = tok
+	if err := s.store.Save(tok); err != nil {
+		log.Printf("Unable to save the refreshed token: %v", redactErr(err))
+	}
+	return tok, nil
+}
 
-	go http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+// reauthRequired は、リフレッシュトークンが拒否された場合に保存されたトークンを削除し、
+// 認証し直す方法を説明する ErrReauthRequired のエラーを返します。
+func (s *savingTokenSource) reauthRequired(err error) error {
+	message := "the saved refresh token was revoked or has expired"
+	if d, ok := s.store.(tokenDeleter); ok {
+		if delErr := d.Delete(); delErr != nil {
+			log.Printf("Unable to delete the stale token: %v", delErr)
+		} else {
+			message += ", the cached token was deleted"
+		}
+	}
+	return fmt.Errorf("%w: %s; run the command again to authorize (and update YOUTUBE_REFRESH_TOKEN if it is set): %v",
+		ErrReauthRequired, message, redactErr(err))
+}
+
+// startWebServerは、webListenAddr でリッスンするウェブサーバーを起動します。
+// そのポートが使用中などで待ち受けられない場合は、OSが選んだ空いているポートで待ち受けます。
+// ウェブサーバーは、3段階の認証フローでのOAuthコードを待機します。
+// stateが送ったものと一致しない要求は、別の認証で得たコードを注入されないよう拒否します。
+// 有効なコールバックは最初の1回だけをcodeChに送り、その応答の後でサーバーを閉じます。
+// 同意を拒否した場合など、Googleがerrorを付けてリダイレクトした場合は、待ち続けないようそのエラーをerrChに送ります。
+// ブラウザが要求するfaviconなど、リダイレクトURI以外のパスへの要求は無視します。
+// 実際に待ち受けているアドレスに対応するリダイレクトURIと、サーバーを返します。
+// コードを受け取る前に諦めた場合でも待ち受けを残さないよう、呼び出し側でサーバーを閉じてください。
+func startWebServer(state string) (codeCh <-chan string, errCh <-chan error, redirect string, server *http.Server, err error) {
+	listener, err := net.Listen("tcp", webListenAddr())
+	if err != nil {
+		fmt.Printf("Unable to listen on %s (%v), using a free port instead\n", webListenAddr(), err)
+		listener, err = net.Listen("tcp", "localhost:0")
+		if err != nil {
+			return nil, nil, "", nil, err
+		}
+	}
+	// 送信がハンドラーを止めないよう、1回分の余裕を持たせる
+	codes := make(chan string, 1)
+	errs := make(chan error, 1)
+	var once sync.Once
+
+	server = &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.FormValue("state") != state {
+			writeAuthPage(w, http.StatusBadRequest, authErrorPage,
+				struct{ Message string }{"The request does not match the authorization started by youtube-go (state mismatch)."})
+			return
+		}
 		code := r.FormValue("code")
-		codeCh <- code // send code to OAuth flow
-		listener.Close()
-		w.Header().Set("Content-Type", "text/plain")
-		fmt.Fprintf(w, "Received code: %v\r\nYou can now safely close this browser window.", code)
-	}))
-
-	return codeCh, nil
-}
-
-// openURLは指定された場所にブラウザウィンドウを開きます。
-// このコードは元々以下に表示されました：
-//
-//	http://stackoverflow.com/questions/10377243/how-can-i-launch-a-process-that-is-not-a-file-in-go
-func openURL(url string) error {
-	var err error
-	switch runtime.GOOS {
-	case "linux":
-		err = exec.Command("xdg-open", url).Start()
-	case "windows":
-		err = exec.Command("rundll32", "url.dll,FileProtocolHandler", "http://localhost:4001/").Start()
-	case "darwin":
-		err = exec.Command("open", url).Start()
-	default:
-		err = fmt.Errorf("Cannot open URL %s on this platform", url)
-	}
-	return err
+		if e := r.FormValue("error"); e != "" || code == "" {
+			// 同意を拒否した場合などはコードがないため、空のコードでフローを進めない
+			message := "The redirect did not include an authorization code."
+			if e != "" {
+				message = "Google returned an error: " + e + "."
+				once.Do(func() {
+					errs <- fmt.Errorf("authorization failed: Google returned %s", e)
+				})
+			}
+			writeAuthPage(w, http.StatusBadRequest, authErrorPage, struct{ Message string }{message})
+			return
+		}
+		// リダイレクトが重ねて届いた場合も、フローに渡すのは最初のコードだけにする
+		once.Do(func() {
+			codes <- code // send code to OAuth flow
+			// 応答を書き終えてから閉じるよう、Shutdownは別のゴルーチンで待つ
+			go server.Shutdown(context.Background())
+		})
+		writeAuthPage(w, http.StatusOK, successPage, struct{ Code string }{code})
+	})}
+	go server.Serve(listener)
+
+	return codes, errs, "http://" + listener.Addr().String(), server, nil
+}
+
+// writeAuthPage は、dataでtmplを実行したHTMLをstatusで応答します。
+// テンプレートの実行に失敗した場合は、応答の状態が分かるようプレーンテキストで補います。
+func writeAuthPage(w http.ResponseWriter, status int, tmpl *template.Template, data interface{}) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "%s\r\nReturn to the terminal; you can close this browser window.", http.StatusText(status))
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
+
+// authCodeRequest は、認証コードのフローの1回分のstateとPKCEのコード検証子です。
+// 実行ごとに生成し、固定の値を使い回さないようにします。
+type authCodeRequest struct {
+	state    string
+	verifier string
+}
+
+// newAuthCodeRequest は、ランダムなstateとコード検証子を持つ authCodeRequest を返します。
+func newAuthCodeRequest() (*authCodeRequest, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("Unable to generate the OAuth state: %v", err)
+	}
+	return &authCodeRequest{
+		state:    base64.RawURLEncoding.EncodeToString(b),
+		verifier: oauth2.GenerateVerifier(),
+	}, nil
+}
+
+// authURL は、stateとコードチャレンジを含む認証URLを返します。
+func (a *authCodeRequest) authURL(config *oauth2.Config) string {
+	return config.AuthCodeURL(a.state, oauth2.AccessTypeOffline, oauth2.S256ChallengeOption(a.verifier))
+}
+
+// exchange は、認証コードをコード検証子とともにアクセストークンと交換します。
+func (a *authCodeRequest) exchange(ctx context.Context, config *oauth2.Config, code string) (*oauth2.Token, error) {
+	return exchangeToken(ctx, config, code, oauth2.VerifierOption(a.verifier))
 }
 
 // 認証コードをアクセストークンと交換する
-func exchangeToken(config *oauth2.Config, code string) (*oauth2.Token, error) {
-	tok, err := config.Exchange(oauth2.NoContext, code)
+func exchangeToken(ctx context.Context, config *oauth2.Config, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	tok, err := config.Exchange(withHTTPClient(ctx), code, opts...)
 	if err != nil {
-		log.Fatalf("Unable to retrieve token %v", err)
+		return nil, fmt.Errorf("Unable to retrieve token: %v", redactErr(err))
 	}
 	return tok, nil
 }
 
 // getTokenFromPromptはConfigを使用してTokenをリクエストし、ユーザーに対してコマンドラインでトークンを入力するよう促します。
+// ブラウザを開けない環境でも使えるよう、認証URLを表示するだけでブラウザは開きません。
+// リダイレクトを受けるウェブサーバーも起動し、リダイレクトが届いた場合はそのコードを使います。
+// 別の端末のブラウザで認証した場合は、リダイレクト先のURLまたは認証コードを入力してもらいます。
 // 取得されたTokenが戻り値になります。
-func getTokenFromPrompt(config *oauth2.Config, authURL string) (*oauth2.Token, error) {
+func getTokenFromPrompt(ctx context.Context, config *oauth2.Config) (*oauth2.Token, error) {
+	req, err := newAuthCodeRequest()
+	if err != nil {
+		return nil, err
+	}
+	codeCh, deniedCh, redirect, server, err := startWebServer(req.state)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to start a web server: %v", err)
+	}
+	defer server.Close()
+	config.RedirectURL = redirect
+	authURL := req.authURL(config)
+
+	fmt.Printf("Go to the following link in your browser:\n%v\n", authURL)
+	if authQR {
+		printAuthQR(authURL)
+	}
+	fmt.Printf("If the browser cannot reach %s after the authorization flow, "+
+		"copy the URL from its address bar and enter it here: ", redirect)
+
+	// 入力の読み込みは中断できないため、ウェブサーバーが先にコードを受け取った場合は読み込みを残したままにする。
+	// 残った読み込みが後から os.Stdin を参照しないよう、ここで取り出しておく
+	stdin := os.Stdin
+	inputCh := make(chan string, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		var input string
+		if _, err := fmt.Fscan(stdin, &input); err != nil {
+			errCh <- err
+			return
+		}
+		inputCh <- input
+	}()
+
 	var code string
-	fmt.Printf("Go to the following link in your browser. After completing "+
-		"the authorization flow, enter the authorization code on the command "+
-		"line: \n%v\n", authURL)
+	select {
+	case code = <-codeCh:
+		fmt.Println("\nReceived the authorization code from the browser")
+	case err := <-deniedCh:
+		fmt.Println()
+		return nil, err
+	case input := <-inputCh:
+		code, err = parseAuthCode(input, req.state)
+		if err != nil {
+			return nil, err
+		}
+	case err := <-errCh:
+		return nil, fmt.Errorf("Unable to read authorization code: %v", err)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return req.exchange(ctx, config, code)
+}
 
-	if _, err := fmt.Scan(&code); err != nil {
-		log.Fatalf("Unable to read authorization code %v", err)
+// parseAuthCode は、入力されたリダイレクト先のURLから認証コードを取り出します。
+// ウェブサーバーと同じく、URLのstateが送ったものと一致しない場合は拒否します。
+// URLでない場合は、入力を認証コードそのものとして扱います。
+func parseAuthCode(input, state string) (string, error) {
+	input = strings.TrimSpace(input)
+	if !strings.Contains(input, "?") {
+		return input, nil
 	}
-	fmt.Println(authURL)
-	return exchangeToken(config, code)
+	u, err := url.Parse(input)
+	if err != nil {
+		return "", fmt.Errorf("Unable to parse the redirect URL: %v", err)
+	}
+	query := u.Query()
+	if e := query.Get("error"); e != "" {
+		return "", fmt.Errorf("authorization failed: %s", e)
+	}
+	if query.Get("state") != state {
+		return "", fmt.Errorf("the state in the redirect URL does not match, copy the URL of this authorization request")
+	}
+	code := query.Get("code")
+	if code == "" {
+		return "", fmt.Errorf("the redirect URL has no authorization code")
+	}
+	return code, nil
 }
 
 // getTokenFromWebはConfigを使用してTokenをリクエストします。
+// リダイレクトURIは、ウェブサーバーが実際に待ち受けているアドレスに合わせて置き換えます。
+// Googleのデスクトップアプリ向けのクライアントは、ループバックアドレスであればどのポートでも受け付けます。
+// authTimeout を過ぎても認証が完了しない場合は、ウェブサーバーを閉じてエラーを返します。
 // 取得されたTokenが戻り値になります。
-func getTokenFromWeb(config *oauth2.Config, authURL string) (*oauth2.Token, error) {
-	codeCh, err := startWebServer()
+func getTokenFromWeb(ctx context.Context, config *oauth2.Config) (*oauth2.Token, error) {
+	req, err := newAuthCodeRequest()
+	if err != nil {
+		return nil, err
+	}
+	codeCh, deniedCh, redirect, server, err := startWebServer(req.state)
 	if err != nil {
 		fmt.Printf("Unable to start a web server.")
 		return nil, err
 	}
+	defer server.Close()
+	config.RedirectURL = redirect
+	authURL := req.authURL(config)
 
-	err = openURL(authURL)
-	if err != nil {
-		log.Fatalf("Unable to open authorization URL in web server: %v", err)
-	} else {
-		fmt.Println("Your browser has been opened to an authorization URL.",
-			"This program will resume once authorization has been provided.")
-		fmt.Println(authURL)
+	// ブラウザを開けない環境でも手で認証を続けられるよう、開く前にURLを表示する
+	fmt.Println("Opening the authorization URL in your browser.",
+		"This program will resume once authorization has been provided.")
+	fmt.Println(authURL)
+	if err := openURL(authURL); err != nil {
+		fmt.Printf("Unable to open a browser (%v), open the URL above manually\n", err)
 	}
 
 	// ウェブサーバーがコードを取得するのを待ちます。
-	code := <-codeCh
-	return exchangeToken(config, code)
+	var expired <-chan time.Time
+	if authTimeout > 0 {
+		timer := time.NewTimer(authTimeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	var code string
+	select {
+	case code = <-codeCh:
+	case err := <-deniedCh:
+		return nil, err
+	case <-expired:
+		fmt.Printf("The authorization window expired after %v, run the command again to retry\n", authTimeout)
+		return nil, fmt.Errorf("authorization was not completed within -auth-timeout %v", authTimeout)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return req.exchange(ctx, config, code)
+}
+
+// getTokenFromDeviceはConfigを使用して、デバイス認可グラントでTokenをリクエストします。
+// 表示した確認URLを別の端末で開き、ユーザーコードを入力してもらいます。
+// 許可されるまで、応答のintervalの間隔でトークンエンドポイントに問い合わせ、expires_inを過ぎると諦めます。
+// クライアントIDの種類は「テレビと入力が限られたデバイス」である必要があり、
+// Googleはこのフローでyoutube.uploadのスコープを許可しないため、-scopes youtube を使います。
+// 取得されたTokenが戻り値になります。
+func getTokenFromDevice(ctx context.Context, config *oauth2.Config) (*oauth2.Token, error) {
+	// クライアントシークレットのファイルにはデバイス認可のエンドポイントが含まれない
+	if config.Endpoint.DeviceAuthURL == "" {
+		config.Endpoint.DeviceAuthURL = google.Endpoint.DeviceAuthURL
+	}
+	ctx = withHTTPClient(ctx)
+	da, err := config.DeviceAuth(ctx)
+	if err != nil {
+		var rErr *oauth2.RetrieveError
+		if errors.As(err, &rErr) && rErr.ErrorCode == "invalid_scope" {
+			return nil, fmt.Errorf("Unable to start the device flow: %v (the device flow does not allow the upload scope, use -scopes youtube)", redactErr(err))
+		}
+		return nil, fmt.Errorf("Unable to start the device flow: %v", redactErr(err))
+	}
+
+	fmt.Printf("On another device, go to %v and enter the code %v\n", da.VerificationURI, da.UserCode)
+	if authQR {
+		verificationURL := da.VerificationURIComplete
+		if verificationURL == "" {
+			verificationURL = da.VerificationURI
+		}
+		printAuthQR(verificationURL)
+	}
+	if !da.Expiry.IsZero() {
+		fmt.Printf("Waiting for authorization, the code expires at %v\n", da.Expiry.Local().Format(time.Kitchen))
+	}
+
+	tok, err := config.DeviceAccessToken(ctx, da)
+	if errors.Is(err, context.DeadlineExceeded) && !da.Expiry.IsZero() && time.Now().After(da.Expiry) {
+		return nil, fmt.Errorf("the device code expired before authorization, run the command again")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to retrieve token: %v", redactErr(err))
+	}
+	return tok, nil
 }
 
 // tokenCacheFile は、クレデンシャル・ファイルのパス/ファイル名を生成します。
 // 生成されたクレデンシャル・パス/ファイル名を返します。
+// -account が指定された場合は、アカウントごとに別のファイルを使います。
+// YOUTUBE_GO_CONFIG_DIR が設定されていない場合、以前の ~/.credentials にだけ
+// ファイルがあればそちらを使い続けます。
 func tokenCacheFile() (string, error) {
-	usr, err := user.Current()
+	if err := validateAccount(account); err != nil {
+		return "", err
+	}
+	name := url.QueryEscape(tokenCacheName(account))
+	file, err := configPath("credentials", name)
 	if err != nil {
 		return "", err
 	}
-	tokenCacheDir := filepath.Join(usr.HomeDir, ".credentials")
-	os.MkdirAll(tokenCacheDir, 0700)
-	return filepath.Join(tokenCacheDir,
-		url.QueryEscape("youtube-go.json")), err
+	if os.Getenv(configDirEnv) != "" {
+		return file, nil
+	}
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		if usr, err := user.Current(); err == nil {
+			legacy := filepath.Join(usr.HomeDir, ".credentials", name)
+			if _, err := os.Stat(legacy); err == nil {
+				return legacy, nil
+			}
+		}
+	}
+	return file, nil
 }
 
 // tokenFromFile は指定されたファイル・パスからトークンを取得します。
 // 取得したトークンと、発生した読み取りエラーを返します。
+// saveToken はファイルを置き換えて書き込むため、書き込み途中の内容を読むことはありません。
 func tokenFromFile(file string) (*oauth2.Token, error) {
+	tokenFileMu.Lock()
+	defer tokenFileMu.Unlock()
 	f, err := os.Open(file)
 	if err != nil {
 		return nil, err
 	}
-	t := &oauth2.Token{}
-	err = json.NewDecoder(f).Decode(t)
+	var t cachedToken
+	err = json.NewDecoder(f).Decode(&t)
 	defer f.Close()
-	return t, err
+	if t.Token == nil {
+		t.Token = &oauth2.Token{}
+	}
+	return withScopes(t.Token, strings.Fields(t.Scope)), err
+}
+
+// removeTokenFile は、トークンのキャッシュファイルを削除します。ファイルがない場合は何もしません。
+func removeTokenFile(file string) error {
+	tokenFileMu.Lock()
+	defer tokenFileMu.Unlock()
+	if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// cachedToken は、トークンのキャッシュファイルの形式です。
+// oauth2.TokenのJSONに、許可されたスコープをscopeとしてスペース区切りで加えます。
+type cachedToken struct {
+	*oauth2.Token
+	Scope string `json:"scope,omitempty"`
 }
 
 // saveTokenはファイル・パスを使用してファイルを作成し、トークンをその中に格納します。
-func saveToken(file string, token *oauth2.Token) {
+// 複数のゴルーチンやプロセスが同時に更新してもファイルが壊れないよう、ロックを取得してから書き込みます。
+func saveToken(file string, token *oauth2.Token) error {
 	fmt.Println("trying to save token")
 	fmt.Printf("Saving credential file to: %s\n", file)
-	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	tokenFileMu.Lock()
+	defer tokenFileMu.Unlock()
+	unlock, err := lockFile(file)
 	if err != nil {
-		log.Fatalf("Unable to cache oauth token: %v", err)
+		return fmt.Errorf("Unable to cache oauth token: %v", err)
 	}
-	defer f.Close()
-	json.NewEncoder(f).Encode(token)
+	defer unlock()
+	err = writeFileAtomic(file, func(f *os.File) error {
+		return newFileJSONEncoder(f).Encode(cachedToken{Token: token, Scope: strings.Join(tokenScopes(token), " ")})
+	})
+	if err != nil {
+		return fmt.Errorf("Unable to cache oauth token: %v", err)
+	}
+	return nil
 }
@@ -0,0 +1,469 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/youtube/v3"
+)
+
+// writeClientSecret は、トークンエンドポイントが tokenURL のクライアントシークレットのファイルを作成し、
+// clientSecretFileEnv に設定します。
+func writeClientSecret(t *testing.T, tokenURL string) {
+	t.Helper()
+	var secret clientSecret
+	secret.Installed.ClientID = "client-id"
+	secret.Installed.ClientSecret = "client-secret"
+	secret.Installed.AuthUri = "https://accounts.google.com/o/oauth2/auth"
+	secret.Installed.TokenUri = tokenURL
+	secret.Installed.RedirectUris = []string{redirectURL()}
+	b, err := json.Marshal(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "client_secret.json")
+	if err := os.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(clientSecretFileEnv, path)
+}
+
+func TestTokenSourceRefreshesExpiredToken(t *testing.T) {
+	refreshes := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if got := r.PostForm.Get("grant_type"); got != "refresh_token" {
+			t.Errorf("grant_type = %q, want refresh_token", got)
+		}
+		if got := r.PostForm.Get("refresh_token"); got != "refresh" {
+			t.Errorf("refresh_token = %q, want refresh", got)
+		}
+		refreshes++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"fresh","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer srv.Close()
+	writeClientSecret(t, srv.URL)
+
+	scopes := []string{youtube.YoutubeUploadScope}
+	store := fileTokenStore{path: filepath.Join(t.TempDir(), "token.json")}
+	expired := withScopes(&oauth2.Token{
+		AccessToken:  "stale",
+		RefreshToken: "refresh",
+		TokenType:    "Bearer",
+		Expiry:       time.Now().Add(-time.Hour),
+	}, scopes)
+	if err := store.Save(expired); err != nil {
+		t.Fatal(err)
+	}
+
+	ts, err := TokenSource(context.Background(), "", scopes, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err := ts.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken != "fresh" {
+		t.Errorf("access token = %q, want fresh", tok.AccessToken)
+	}
+	if refreshes != 1 {
+		t.Errorf("token endpoint called %d times, want 1", refreshes)
+	}
+	saved, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.AccessToken != "fresh" || saved.RefreshToken != "refresh" {
+		t.Errorf("saved token = %q/%q, want fresh/refresh", saved.AccessToken, saved.RefreshToken)
+	}
+}
+
+func TestConcurrentTokenSavesLeaveValidJSON(t *testing.T) {
+	t.Setenv(configDirEnv, t.TempDir())
+	store, err := cacheTokenStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const savers = 20
+	var wg sync.WaitGroup
+	for i := 0; i < savers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tok := &oauth2.Token{
+				AccessToken:  fmt.Sprintf("access-%d", i),
+				RefreshToken: "refresh",
+				Expiry:       time.Now().Add(time.Hour),
+			}
+			if err := store.Save(tok); err != nil {
+				t.Errorf("Save %d: %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	b, err := os.ReadFile(store.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cached cachedToken
+	if err := json.Unmarshal(b, &cached); err != nil {
+		t.Fatalf("token cache is not valid JSON: %v\n%s", err, b)
+	}
+	if cached.Token == nil || !strings.HasPrefix(cached.AccessToken, "access-") || cached.RefreshToken != "refresh" {
+		t.Errorf("token cache = %s, want one of the saved tokens", b)
+	}
+	if _, err := os.Stat(store.path + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("lock file left behind: %v", err)
+	}
+}
+
+func TestReauthorizationKeepsCachedScopes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if got := r.PostForm.Get("grant_type"); got != "authorization_code" {
+			t.Errorf("grant_type = %q, want authorization_code", got)
+		}
+		// scopeを返さない場合は、要求したスコープが許可されたものとして記録される
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"new","refresh_token":"refresh","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer srv.Close()
+	writeClientSecret(t, srv.URL)
+	t.Setenv(oauthPortEnv, "0")
+	defer func(mode string) { authMode = mode }(authMode)
+	authMode = authModePrompt
+
+	// プロンプトのフローには、標準入力から認証コードを渡す
+	stdin, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(f *os.File) { os.Stdin = f }(os.Stdin)
+	os.Stdin = stdin
+	w.WriteString("auth-code\n")
+	w.Close()
+
+	cached := []string{youtube.YoutubeReadonlyScope, youtube.YoutubeForceSslScope}
+	store := fileTokenStore{path: filepath.Join(t.TempDir(), "token.json")}
+	old := withScopes(&oauth2.Token{AccessToken: "old", RefreshToken: "old", Expiry: time.Now().Add(time.Hour)}, cached)
+	if err := store.Save(old); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := TokenSource(context.Background(), "", []string{youtube.YoutubeUploadScope}, store); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.AccessToken != "new" {
+		t.Fatalf("saved access token = %q, want the re-authorized token", saved.AccessToken)
+	}
+	want := append(cached, youtube.YoutubeUploadScope)
+	if missing := missingScopes(tokenScopes(saved), want); len(missing) > 0 {
+		t.Errorf("re-authorized token lost scopes %v, granted %v", missing, tokenScopes(saved))
+	}
+}
+
+// captureAuthURL は、テストの間だけ標準出力をパイプに向け、表示された認証URLをurlsに送ります。
+func captureAuthURL(t *testing.T) <-chan string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	t.Cleanup(func() {
+		os.Stdout = stdout
+		w.Close()
+	})
+	urls := make(chan string, 1)
+	go func() {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			if line := scanner.Text(); strings.HasPrefix(line, "http") && strings.Contains(line, "redirect_uri=") {
+				urls <- line
+			}
+		}
+	}()
+	return urls
+}
+
+// completeAuthInBrowser は、ブラウザの代わりに認証URLのredirect_uriへコードとstateを付けてリダイレクトし、
+// 認証URLのredirect_uriを返します。
+func completeAuthInBrowser(t *testing.T, authURL string) string {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Error(err)
+		return ""
+	}
+	redirect := u.Query().Get("redirect_uri")
+	res, err := http.Get(redirect + "?" + url.Values{"code": {"auth-code"}, "state": {u.Query().Get("state")}}.Encode())
+	if err != nil {
+		t.Error(err)
+		return redirect
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("redirect to %s: %s", redirect, res.Status)
+	}
+	return redirect
+}
+
+func TestAuthFlowRedirectConsistency(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		loopback bool
+	}{
+		{"web", authModeWeb, true},
+		{"prompt", authModePrompt, true},
+		{"device", authModeDevice, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu            sync.Mutex
+				tokenRedirect []string
+				deviceForm    url.Values
+			)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				r.ParseForm()
+				w.Header().Set("Content-Type", "application/json")
+				mu.Lock()
+				defer mu.Unlock()
+				switch r.URL.Path {
+				case "/device":
+					deviceForm = r.PostForm
+					w.Write([]byte(`{"device_code":"device","user_code":"USER-CODE","verification_url":"https://example.com/device","expires_in":60,"interval":1}`))
+				case "/token":
+					tokenRedirect = append(tokenRedirect, r.PostForm.Get("redirect_uri"))
+					w.Write([]byte(`{"access_token":"access","refresh_token":"refresh","token_type":"Bearer","expires_in":3600}`))
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer srv.Close()
+			t.Setenv(oauthPortEnv, "0")
+			t.Setenv(browserEnv, "true")
+			defer func(mode string) { authMode = mode }(authMode)
+			authMode = tt.mode
+
+			// プロンプトのフローが標準入力を読んでも終わらないよう、書き込まないパイプを渡す
+			stdin, stdinW, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stdinW.Close()
+			defer func(f *os.File) { os.Stdin = f }(os.Stdin)
+			os.Stdin = stdin
+
+			config := &oauth2.Config{
+				ClientID: "client-id",
+				Endpoint: oauth2.Endpoint{
+					AuthURL:       "https://accounts.google.com/o/oauth2/auth",
+					TokenURL:      srv.URL + "/token",
+					DeviceAuthURL: srv.URL + "/device",
+				},
+				RedirectURL: redirectURL(),
+				Scopes:      []string{youtube.YoutubeScope},
+			}
+			authURLs := captureAuthURL(t)
+			browserRedirect := make(chan string, 1)
+			if tt.loopback {
+				go func() {
+					select {
+					case u := <-authURLs:
+						browserRedirect <- completeAuthInBrowser(t, u)
+					case <-time.After(5 * time.Second):
+						t.Error("no authorization URL was printed")
+						browserRedirect <- ""
+					}
+				}()
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			tok, err := authorize(ctx, config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tok.AccessToken != "access" {
+				t.Errorf("access token = %q, want access", tok.AccessToken)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(tokenRedirect) == 0 {
+				t.Fatal("token endpoint was not called")
+			}
+			if !tt.loopback {
+				if deviceForm.Has("redirect_uri") || tokenRedirect[len(tokenRedirect)-1] != "" {
+					t.Errorf("device flow sent a redirect_uri (device %q, token %q)", deviceForm.Get("redirect_uri"), tokenRedirect)
+				}
+				return
+			}
+			redirect := <-browserRedirect
+			if u, err := url.Parse(redirect); err != nil || u.Scheme != "http" || (u.Hostname() != "127.0.0.1" && u.Hostname() != "localhost") {
+				t.Errorf("redirect_uri %q is not a loopback address", redirect)
+			}
+			if config.RedirectURL != redirect {
+				t.Errorf("config.RedirectURL = %q, want the authorization URL's redirect_uri %q", config.RedirectURL, redirect)
+			}
+			if tokenRedirect[0] != redirect {
+				t.Errorf("token exchange redirect_uri = %q, want %q", tokenRedirect[0], redirect)
+			}
+		})
+	}
+}
+
+func TestAuthFlowReturnsWhenConsentIsDenied(t *testing.T) {
+	for _, mode := range []string{authModeWeb, authModePrompt} {
+		t.Run(mode, func(t *testing.T) {
+			t.Setenv(oauthPortEnv, "0")
+			t.Setenv(browserEnv, "true")
+			defer func(mode string) { authMode = mode }(authMode)
+			authMode = mode
+
+			stdin, stdinW, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stdinW.Close()
+			defer func(f *os.File) { os.Stdin = f }(os.Stdin)
+			os.Stdin = stdin
+
+			config := &oauth2.Config{
+				ClientID: "client-id",
+				Endpoint: oauth2.Endpoint{
+					AuthURL:  "https://accounts.google.com/o/oauth2/auth",
+					TokenURL: "https://oauth2.example.com/token",
+				},
+				RedirectURL: redirectURL(),
+				Scopes:      []string{youtube.YoutubeScope},
+			}
+			authURLs := captureAuthURL(t)
+			go func() {
+				select {
+				case authURL := <-authURLs:
+					// 同意画面で拒否した場合、Googleはerrorとstateを付けてリダイレクトする
+					u, err := url.Parse(authURL)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					q := url.Values{"error": {"access_denied"}, "state": {u.Query().Get("state")}}
+					res, err := http.Get(u.Query().Get("redirect_uri") + "?" + q.Encode())
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					res.Body.Close()
+				case <-time.After(5 * time.Second):
+					t.Error("no authorization URL was printed")
+				}
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			_, err = authorize(ctx, config)
+			if err == nil || !strings.Contains(err.Error(), "access_denied") {
+				t.Fatalf("authorize error = %v, want the access_denied error from Google", err)
+			}
+			if ctx.Err() != nil {
+				t.Error("authorize waited for the timeout instead of returning on the denial")
+			}
+		})
+	}
+}
+
+func TestReadClientSecretPrefersGivenPath(t *testing.T) {
+	writeClientSecret(t, "https://oauth2.example.com/token")
+	path := os.Getenv(clientSecretFileEnv)
+	t.Setenv(clientSecretFileEnv, filepath.Join(t.TempDir(), "missing.json"))
+
+	b, err := readClientSecret(path)
+	if err != nil {
+		t.Fatalf("readClientSecret(%q) = %v, want the given file", path, err)
+	}
+	if !strings.Contains(string(b), "https://oauth2.example.com/token") {
+		t.Errorf("readClientSecret(%q) = %s, want the given file's contents", path, b)
+	}
+	if _, err := readClientSecret(""); err == nil || !strings.Contains(err.Error(), "client secret file not found") {
+		t.Errorf("readClientSecret(\"\") = %v, want the missing %s file reported", err, clientSecretFileEnv)
+	}
+}
+
+func TestTokenSourceRequestsEveryScope(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"new","refresh_token":"refresh","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer srv.Close()
+	writeClientSecret(t, srv.URL)
+	t.Setenv(oauthPortEnv, "0")
+	defer func(mode string) { authMode = mode }(authMode)
+	authMode = authModePrompt
+
+	stdin, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(f *os.File) { os.Stdin = f }(os.Stdin)
+	os.Stdin = stdin
+	w.WriteString("auth-code\n")
+	w.Close()
+
+	// アップロードのスコープだけを許可されたトークンは、読み取りのスコープを要求すると使わない
+	store := fileTokenStore{path: filepath.Join(t.TempDir(), "token.json")}
+	old := withScopes(&oauth2.Token{AccessToken: "old", RefreshToken: "old", Expiry: time.Now().Add(time.Hour)},
+		[]string{youtube.YoutubeUploadScope})
+	if err := store.Save(old); err != nil {
+		t.Fatal(err)
+	}
+	authURLs := captureAuthURL(t)
+	requested := []string{youtube.YoutubeUploadScope, youtube.YoutubeReadonlyScope}
+	ts, err := TokenSource(context.Background(), "", requested, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok, err := ts.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken != "new" {
+		t.Errorf("access token = %q, want the re-authorized token", tok.AccessToken)
+	}
+
+	var authURL string
+	select {
+	case authURL = <-authURLs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no authorization URL was printed")
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if missing := missingScopes(strings.Fields(u.Query().Get("scope")), requested); len(missing) > 0 {
+		t.Errorf("authorization URL %s does not request %v", authURL, missing)
+	}
+}
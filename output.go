@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// compactJSON は、トークンのキャッシュやエクスポートしたファイルを1行のJSONで書き出すかどうかです。
+// 既定では、中身を確認しやすいようインデントして書き出します。
+var compactJSON bool
+
+// newFileJSONEncoder は、人が読むことのあるファイル向けのJSONエンコーダーを返します。
+// compactJSON が設定されていなければインデントします。読み込む側はどちらの形式でも読めます。
+func newFileJSONEncoder(w io.Writer) *json.Encoder {
+	enc := json.NewEncoder(w)
+	if !compactJSON {
+		enc.SetIndent("", "  ")
+	}
+	return enc
+}
+
+// commandEnvelope は、-json を指定したサブコマンドが標準出力に書き出すJSONの形式です。
+// どのサブコマンドでも同じ形式になるため、スクリプトから同じ方法で結果を読み取れます。
+type commandEnvelope struct {
+	Command string `json:"command"`
+	// Status は、成功した場合は"ok"、失敗した場合は"error"です。
+	Status string      `json:"status"`
+	Result interface{} `json:"result,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// -output に指定できる値です。
+const (
+	// outputText は、人が読むためのテキストで表示します。
+	outputText = "text"
+	// outputJSON は、結果そのもの、または失敗した場合は {"error": ...} をJSONで標準出力に書き出します。
+	outputJSON = "json"
+)
+
+// commandOutput は、サブコマンドの結果をテキストまたはJSONで出力します。
+// JSONモードでは、途中の表示が結果のJSONと混ざらないよう、標準出力を標準エラー出力に向けます。
+type commandOutput struct {
+	command string
+	json    *bool
+	format  *string
+	stdout  *os.File
+}
+
+// newCommandOutput は、サブコマンドのフラグに -json と -output を追加した commandOutput を返します。
+// fs.Parseの前に呼び出してください。
+func newCommandOutput(fs *flag.FlagSet, command string) *commandOutput {
+	return &commandOutput{
+		command: command,
+		json:    fs.Bool("json", false, "Print the result as JSON {command, status, result|error} on stdout"),
+		format:  fs.String("output", outputText, `Output format: text, or json to print only the result, or {"error": ...} on failure, as JSON on stdout`),
+	}
+}
+
+// start は、-output の値を検証し、JSONモードであれば以降の表示を標準エラー出力に向けます。
+// fs.Parseの後に呼び出してください。
+func (o *commandOutput) start() {
+	if *o.format != outputText && *o.format != outputJSON {
+		log.Fatalf("invalid -output %q, must be %s or %s", *o.format, outputText, outputJSON)
+	}
+	if o.jsonMode() {
+		o.stdout = os.Stdout
+		os.Stdout = os.Stderr
+	}
+}
+
+// jsonMode は、-json または -output=json でJSONを出力するかどうかを返します。
+func (o *commandOutput) jsonMode() bool {
+	return *o.json || *o.format == outputJSON
+}
+
+// finish は、JSONモードであれば結果またはエラーを標準出力に書き出します。
+// -json では commandEnvelope で、-output=json では結果そのものか {"error": ...} で書き出します。
+// errはそのまま返すため、呼び出し元の終了コードは変わりません。
+func (o *commandOutput) finish(result interface{}, err error) error {
+	if !o.jsonMode() {
+		return err
+	}
+	if o.stdout != nil {
+		os.Stdout = o.stdout
+	}
+	if !*o.json {
+		var v interface{} = result
+		if err != nil {
+			v = struct {
+				Error string `json:"error"`
+			}{redactErr(err)}
+		}
+		if encErr := json.NewEncoder(os.Stdout).Encode(v); encErr != nil && err == nil {
+			return encErr
+		}
+		return err
+	}
+	envelope := commandEnvelope{Command: o.command, Status: "ok", Result: result}
+	if err != nil {
+		envelope.Status = "error"
+		envelope.Result = nil
+		envelope.Error = redactErr(err)
+	}
+	if encErr := json.NewEncoder(os.Stdout).Encode(envelope); encErr != nil && err == nil {
+		return encErr
+	}
+	return err
+}
+
+// fatal は、log.Fatal と同じくエラーを表示して終了します。
+// JSONモードであれば、終了する前にエラーを commandEnvelope で標準出力に書き出します。
+func (o *commandOutput) fatal(v ...interface{}) {
+	o.finish(nil, errors.New(fmt.Sprint(v...)))
+	log.Fatal(v...)
+}
+
+// fatalf は、log.Fatalf と同じくエラーを表示して終了します。JSONモードでの動作は fatal と同じです。
+func (o *commandOutput) fatalf(format string, v ...interface{}) {
+	o.fatal(fmt.Sprintf(format, v...))
+}
+
+// exit は、エラーを表示して指定された終了コードで終了します。JSONモードでの動作は fatal と同じです。
+func (o *commandOutput) exit(code int, err error) {
+	o.finish(nil, err)
+	log.Print(err)
+	os.Exit(code)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"testing"
+)
+
+// captureStdout は、fnを実行する間に標準出力に書き出された内容を返します。
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	fn()
+	w.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestCommandOutputFormats(t *testing.T) {
+	result := uploadResult{ID: "video-id", URL: "https://youtu.be/video-id", Status: "uploaded"}
+	tests := []struct {
+		name string
+		args []string
+		err  error
+		want string
+	}{
+		{"output json", []string{"-output", "json"}, nil,
+			`{"id":"video-id","url":"https://youtu.be/video-id","status":"uploaded"}` + "\n"},
+		{"output json error", []string{"-output=json"}, errors.New("upload failed"),
+			`{"error":"upload failed"}` + "\n"},
+		{"json envelope", []string{"-json"}, nil,
+			`{"command":"upload","status":"ok","result":{"id":"video-id","url":"https://youtu.be/video-id","status":"uploaded"}}` + "\n"},
+		{"text", nil, nil, "progress\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("upload", flag.ContinueOnError)
+			out := newCommandOutput(fs, "upload")
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			var err error
+			got := captureStdout(t, func() {
+				out.start()
+				// JSONモードでは、途中の表示は標準エラー出力に向く
+				os.Stdout.WriteString("progress\n")
+				err = out.finish(result, tt.err)
+			})
+			if err != tt.err {
+				t.Errorf("finish = %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("stdout = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
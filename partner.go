@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// -onbehalf-content-owner と -onbehalf-channel-id は、コンテンツ所有者が管理するチャンネルへのアップロードで、
+// videos.insert の onBehalfOfContentOwner と onBehalfOfContentOwnerChannel として送ります。
+// どちらもYouTubeパートナーのパラメーターで、認証するアカウントがそのコンテンツ所有者のYouTube CMSに
+// リンクされている必要があります。リンクされていない場合、APIはアップロードを403で拒否します。
+
+// managedChannels は、コンテンツ所有者が管理するチャンネルの一覧を返します。
+func managedChannels(service *youtube.Service, contentOwner string) ([]*youtube.Channel, error) {
+	var channels []*youtube.Channel
+	call := service.Channels.List([]string{"snippet"}).
+		ManagedByMe(true).
+		OnBehalfOfContentOwner(contentOwner).
+		MaxResults(50)
+	err := call.Pages(context.Background(), func(page *youtube.ChannelListResponse) error {
+		channels = append(channels, page.Items...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing channels managed by %s: %w", contentOwner, err)
+	}
+	return channels, nil
+}
+
+// validateManagedChannel は、アップロード先のチャンネルがコンテンツ所有者の管理するチャンネルかどうかを検証します。
+// 一致しない場合は、管理されているチャンネルの一覧を含むエラーを返します。
+func validateManagedChannel(service *youtube.Service, contentOwner, channelID string) error {
+	channels, err := managedChannels(service, contentOwner)
+	if err != nil {
+		return err
+	}
+	available := make([]string, 0, len(channels))
+	for _, channel := range channels {
+		if channel.Id == channelID {
+			return nil
+		}
+		available = append(available, fmt.Sprintf("%s (%s)", channel.Id, channel.Snippet.Title))
+	}
+	if len(available) == 0 {
+		return fmt.Errorf("channel %s is not managed by content owner %s, which manages no channels", channelID, contentOwner)
+	}
+	return fmt.Errorf("channel %s is not managed by content owner %s, available channels:\n  %s",
+		channelID, contentOwner, strings.Join(available, "\n  "))
+}
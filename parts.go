@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/youtube/v3"
+
+	"github.com/maguro-alternative/youtube-go/upload"
+)
+
+// insertableParts は、Videos.Insertで送信できるリソースの部分です。
+var insertableParts = []string{"snippet", "status", "recordingDetails", "localizations"}
+
+// inferParts は、動画のリソースのうち値が設定されている部分の一覧を返します。
+// メタデータに録画日時(recording_date)やローカライズが含まれていれば、それらも自動的に含まれます。
+func inferParts(video *youtube.Video) []string {
+	return upload.InferParts(video)
+}
+
+// parseParts は、-parts に指定されたカンマ区切りの部分の一覧を解釈します。
+func parseParts(s string) ([]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var parts []string
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		known := false
+		for _, p := range insertableParts {
+			if p == part {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown part %q, must be one of %s", part, strings.Join(insertableParts, ", "))
+		}
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+// resolveParts は、Videos.Insertに渡す部分の一覧を決めます。
+// requested が空の場合は動画のリソースから推測し、指定された場合はそれを優先します。
+// 指定された部分に対応するデータがない場合はエラーを返します。
+func resolveParts(video *youtube.Video, requested []string) ([]string, error) {
+	inferred := inferParts(video)
+	if len(requested) == 0 {
+		return inferred, nil
+	}
+	var missing []string
+	for _, part := range requested {
+		found := false
+		for _, p := range inferred {
+			if p == part {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, part)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("requested parts have no data in the metadata: %s", strings.Join(missing, ", "))
+	}
+	return requested, nil
+}
+
+// minimalParts は、-minimal-parts で送信する部分です。
+var minimalParts = []string{"snippet", "status"}
+
+// stripOptionalParts は、snippetとstatus以外の部分を動画のリソースから取り除き、
+// 取り除いた部分の一覧を返します。メタデータから設定される省略可能な部分は、
+// recording_date によるrecordingDetailsとlocalizationsの2つです。
+// 省略可能な部分を送ると400になるアカウントで、原因を切り分けるために使います。
+func stripOptionalParts(video *youtube.Video) []string {
+	var dropped []string
+	if video.RecordingDetails != nil {
+		video.RecordingDetails = nil
+		dropped = append(dropped, "recordingDetails")
+	}
+	if len(video.Localizations) > 0 {
+		video.Localizations = nil
+		dropped = append(dropped, "localizations")
+	}
+	return dropped
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInferParts(t *testing.T) {
+	localizations := map[string]videoLocalization{"en": {Title: "title"}}
+	tests := []struct {
+		name string
+		meta videoMetadata
+		want []string
+	}{
+		{"snippet and status", videoMetadata{}, []string{"snippet", "status"}},
+		{"recording date", videoMetadata{RecordingDate: "2024-05-01T09:00:00Z"}, []string{"snippet", "status", "recordingDetails"}},
+		{"localizations", videoMetadata{DefaultLanguage: "en", Localizations: localizations}, []string{"snippet", "status", "localizations"}},
+		{"both", videoMetadata{RecordingDate: "2024-05-01T09:00:00Z", DefaultLanguage: "en", Localizations: localizations},
+			[]string{"snippet", "status", "recordingDetails", "localizations"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inferParts(buildVideo(tt.meta)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("inferParts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInferPartsFromMergedMetadataFile(t *testing.T) {
+	base := metadataFile{Title: "title", RecordingDate: "2024-05-01T09:00:00Z"}.metadata()
+	top := metadataFile{DefaultLanguage: "en", Localizations: map[string]videoLocalization{"en": {Title: "title"}}}.metadata()
+	got := inferParts(buildVideo(overlayMetadata(base, top, tagMergeReplace)))
+	want := []string{"snippet", "status", "recordingDetails", "localizations"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("inferParts = %v, want %v", got, want)
+	}
+}
+
+func TestResolveParts(t *testing.T) {
+	video := buildVideo(videoMetadata{RecordingDate: "2024-05-01T09:00:00Z"})
+	tests := []struct {
+		name      string
+		requested []string
+		want      []string
+		wantErr   string
+	}{
+		{"inferred", nil, []string{"snippet", "status", "recordingDetails"}, ""},
+		{"override", []string{"snippet", "status"}, []string{"snippet", "status"}, ""},
+		{"no data", []string{"snippet", "localizations"}, nil, "localizations"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveParts(video, tt.requested)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolveParts = %v, %v, want an error naming %s", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolveParts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// -on-conflict に指定できる値です。
+// 動画がすでに再生リストに含まれている場合の動作を決めます。
+const (
+	onConflictSkip  = "skip"
+	onConflictAdd   = "add"
+	onConflictError = "error"
+)
+
+// validateOnConflict は、-on-conflict の値が有効かどうかを検証します。
+func validateOnConflict(onConflict string) error {
+	switch onConflict {
+	case onConflictSkip, onConflictAdd, onConflictError:
+		return nil
+	}
+	return fmt.Errorf("invalid -on-conflict %q, must be one of %s, %s, %s",
+		onConflict, onConflictSkip, onConflictAdd, onConflictError)
+}
+
+// playlistPositionEnd は、再生リストの末尾に追加することを表す位置です。
+const playlistPositionEnd = -1
+
+// parsePlaylistPosition は、-playlist-position の値を解釈します。
+// 空または"end"の場合は playlistPositionEnd を、それ以外は0から始まる位置を返します。
+func parsePlaylistPosition(s string) (int64, error) {
+	if s == "" || s == "end" {
+		return playlistPositionEnd, nil
+	}
+	position, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || position < 0 {
+		return 0, fmt.Errorf("invalid -playlist-position %q, must be a non-negative number or end", s)
+	}
+	return position, nil
+}
+
+// playlistLength は、再生リストに含まれる動画の数を返します。
+func playlistLength(service *youtube.Service, playlistID string) (int64, error) {
+	response, err := service.PlaylistItems.List([]string{"id"}).
+		PlaylistId(playlistID).
+		MaxResults(0).
+		Do()
+	if err != nil {
+		return 0, err
+	}
+	return response.PageInfo.TotalResults, nil
+}
+
+// playlistContains は、再生リストに指定された動画がすでに含まれているかどうかを返します。
+func playlistContains(service *youtube.Service, playlistID, videoID string) (bool, error) {
+	response, err := service.PlaylistItems.List([]string{"id"}).
+		PlaylistId(playlistID).
+		VideoId(videoID).
+		MaxResults(1).
+		Do()
+	if err != nil {
+		return false, err
+	}
+	return len(response.Items) > 0, nil
+}
+
+// addToPlaylist は、動画を再生リストのpositionの位置に追加します。
+// positionが playlistPositionEnd の場合や再生リストの長さを超える場合は末尾に追加します。
+// 動画がすでに含まれている場合の動作は onConflict に従います。
+// 再生リストが存在しない場合や権限がない場合は、APIのエラーをそのまま返します。
+func addToPlaylist(service *youtube.Service, playlistID, videoID, onConflict string, position int64) error {
+	if onConflict != onConflictAdd {
+		exists, err := playlistContains(service, playlistID, videoID)
+		if err != nil {
+			return fmt.Errorf("listing items of playlist %s: %w", playlistID, err)
+		}
+		if exists {
+			if onConflict == onConflictError {
+				return fmt.Errorf("video %s is already in playlist %s", videoID, playlistID)
+			}
+			fmt.Printf("Video %s is already in playlist %s, skipping\n", videoID, playlistID)
+			return nil
+		}
+	}
+
+	item := &youtube.PlaylistItem{
+		Snippet: &youtube.PlaylistItemSnippet{
+			PlaylistId: playlistID,
+			ResourceId: &youtube.ResourceId{
+				Kind:    "youtube#video",
+				VideoId: videoID,
+			},
+		},
+	}
+	if position != playlistPositionEnd {
+		// 長さを超える位置はAPIが拒否するため、その場合は位置を指定せずに末尾に追加する
+		length, err := playlistLength(service, playlistID)
+		if err != nil {
+			return fmt.Errorf("counting items of playlist %s: %w", playlistID, err)
+		}
+		if position < length {
+			item.Snippet.Position = position
+			// 0を送るためにForceSendFieldsで明示する
+			item.Snippet.ForceSendFields = []string{"Position"}
+		} else {
+			fmt.Printf("Playlist %s has %d items, appending instead of inserting at position %d\n",
+				playlistID, length, position)
+		}
+	}
+	inserted, err := service.PlaylistItems.Insert([]string{"snippet"}, item).Do()
+	if err != nil {
+		return fmt.Errorf("adding video %s to playlist %s: %w", videoID, playlistID, withQuotaHint("playlistItems.insert", err))
+	}
+	recordResult("playlistItems.insert", videoID)
+	fmt.Printf("Added video %s to playlist %s at position %d\n", videoID, playlistID, inserted.Snippet.Position)
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// runPreflight は、認証されたアカウントで何ができるかを表示します。
+// 15分を超える動画、カスタムサムネイル、ライブ配信はいずれも電話番号による確認が必要です。
+// APIが直接返すのは長時間アップロードの状態だけなので、他の2つはそこから推測します。
+func runPreflight(args []string) error {
+	fs := flag.NewFlagSet("preflight", flag.ExitOnError)
+	addAccountFlag(fs)
+	out := newCommandOutput(fs, "preflight")
+	fs.Parse(args)
+	out.start()
+
+	result, err := preflight()
+	return out.finish(result, err)
+}
+
+// preflightResult は、preflight の -json で出力するチャンネルごとの結果です。
+type preflightResult struct {
+	ChannelID        string `json:"channel_id"`
+	Title            string `json:"title"`
+	Privacy          string `json:"privacy"`
+	LongUploads      string `json:"long_uploads"`
+	CustomThumbnails bool   `json:"custom_thumbnails"`
+	LiveStreaming    bool   `json:"live_streaming"`
+	MadeForKids      bool   `json:"made_for_kids"`
+}
+
+// preflight は、認証されたアカウントのチャンネルごとに、使える機能を表示して返します。
+func preflight() ([]preflightResult, error) {
+	_, service, err := newService(context.Background(), youtube.YoutubeReadonlyScope)
+	if err != nil {
+		return nil, err
+	}
+	response, err := service.Channels.List([]string{"snippet", "status"}).Mine(true).Do()
+	if err != nil {
+		return nil, fmt.Errorf("listing channels: %w", err)
+	}
+	if len(response.Items) == 0 {
+		return nil, fmt.Errorf("the authenticated user has no channel")
+	}
+
+	var results []preflightResult
+	for _, channel := range response.Items {
+		status := channel.Status
+		verified := status.LongUploadsStatus == "allowed"
+		fmt.Printf("Channel: %s (%s)\n", channel.Snippet.Title, channel.Id)
+		fmt.Printf("  Privacy:                 %s\n", status.PrivacyStatus)
+		fmt.Printf("  Long uploads (>15 min):  %s\n", describeLongUploads(status.LongUploadsStatus))
+		fmt.Printf("  Custom thumbnails:       %s\n", describeVerified(verified))
+		fmt.Printf("  Live streaming:          %s\n", describeVerified(verified))
+		fmt.Printf("  Made for kids (channel): %v\n", status.MadeForKids)
+		results = append(results, preflightResult{
+			ChannelID:        channel.Id,
+			Title:            channel.Snippet.Title,
+			Privacy:          status.PrivacyStatus,
+			LongUploads:      status.LongUploadsStatus,
+			CustomThumbnails: verified,
+			LiveStreaming:    verified,
+			MadeForKids:      status.MadeForKids,
+		})
+	}
+	return results, nil
+}
+
+// describeLongUploads は、longUploadsStatus の値を説明に変換します。
+func describeLongUploads(status string) string {
+	switch status {
+	case "allowed":
+		return "allowed"
+	case "eligible":
+		return "not yet enabled, verify the account at https://www.youtube.com/verify"
+	case "disallowed":
+		return "disallowed, the account is not in good standing"
+	}
+	return "unknown (" + status + ")"
+}
+
+// describeVerified は、確認済みのアカウントが必要な機能の状態を説明に変換します。
+func describeVerified(verified bool) string {
+	if verified {
+		return "allowed (account is verified)"
+	}
+	return "probably not allowed, requires a verified account (https://www.youtube.com/verify)"
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// privacyMismatchReasons は、アップロードした動画の公開設定が要求と異なる場合によくある理由です。
+var privacyMismatchReasons = []string{
+	"a publishAt schedule keeps the video private until it is published",
+	"uploads from unverified API projects are locked to private until the project passes an audit",
+	"the channel is restricted, or the video is held for a copyright or policy review",
+}
+
+// verifyPrivacy は、アップロードした動画の公開設定が要求した値と一致するかどうかを確認します。
+// 一致しない場合は、考えられる理由を含むエラーを返します。
+// レスポンスにstatusが含まれない場合は確認できないため、nilを返します。
+func verifyPrivacy(requested string, video *youtube.Video) error {
+	if requested == "" || video.Status == nil || video.Status.PrivacyStatus == "" {
+		return nil
+	}
+	if strings.EqualFold(video.Status.PrivacyStatus, requested) {
+		return nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "video %s is %s although %s was requested; likely reasons:",
+		video.Id, video.Status.PrivacyStatus, requested)
+	if video.Status.PublishAt != "" {
+		fmt.Fprintf(&b, "\n  - it is scheduled to be published at %s", video.Status.PublishAt)
+	}
+	if video.Status.RejectionReason != "" {
+		fmt.Fprintf(&b, "\n  - it was rejected (%s)", video.Status.RejectionReason)
+	}
+	for _, reason := range privacyMismatchReasons {
+		fmt.Fprintf(&b, "\n  - %s", reason)
+	}
+	return fmt.Errorf("%s", b.String())
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// processingPollInterval は、アップロード後の動画の処理状況を確認する間隔です。
+const processingPollInterval = 15 * time.Second
+
+// waitForProcessing は、動画の uploadStatus が processed か failed などの最終的な状態になるまで、
+// processingPollInterval ごとに処理状況を確認し、進み具合を表示します。
+// Videos.Insertが成功しても、音声だけのファイルや著作権などを理由にサーバー側の処理が失敗することがあるため、
+// 失敗や拒否で終わった場合はその理由を説明するエラーを返します。
+func waitForProcessing(service *youtube.Service, videoID string) error {
+	fmt.Printf("Waiting for video %s to finish processing\n", videoID)
+	lastProgress := ""
+	for {
+		response, err := service.Videos.List([]string{"status", "processingDetails"}).Id(videoID).Do()
+		if err != nil {
+			return fmt.Errorf("checking processing status of video %s: %w", videoID, err)
+		}
+		if len(response.Items) == 0 {
+			return fmt.Errorf("video %s not found while checking its processing status", videoID)
+		}
+		video := response.Items[0]
+		if video.Status == nil {
+			return fmt.Errorf("video %s has no status", videoID)
+		}
+
+		switch video.Status.UploadStatus {
+		case "processed":
+			fmt.Printf("Video %s finished processing\n", videoID)
+			return nil
+		case "failed":
+			return fmt.Errorf("processing of video %s failed: %s", videoID, processingFailure(video))
+		case "rejected":
+			return fmt.Errorf("video %s was rejected: %s", videoID, video.Status.RejectionReason)
+		case "deleted":
+			return fmt.Errorf("video %s was deleted while processing", videoID)
+		}
+		// uploadStatusがuploadedのままでも、処理自体はprocessingDetailsで先に失敗を報告することがある
+		if d := video.ProcessingDetails; d != nil && (d.ProcessingStatus == "failed" || d.ProcessingStatus == "terminated") {
+			return fmt.Errorf("processing of video %s %s: %s", videoID, d.ProcessingStatus, processingFailure(video))
+		}
+
+		// 進み具合が変わらない間は同じ行を繰り返し表示しない
+		if progress := describeProcessingProgress(video.ProcessingDetails); progress != lastProgress {
+			fmt.Printf("Video %s is processing: %s\n", videoID, progress)
+			lastProgress = progress
+		}
+		time.Sleep(processingPollInterval)
+	}
+}
+
+// processingFailure は、処理が失敗した動画から失敗の理由を取り出します。
+func processingFailure(video *youtube.Video) string {
+	if video.Status.FailureReason != "" {
+		return video.Status.FailureReason
+	}
+	if d := video.ProcessingDetails; d != nil && d.ProcessingFailureReason != "" {
+		return d.ProcessingFailureReason
+	}
+	return "no reason given"
+}
+
+// describeProcessingProgress は、processingDetails の進み具合を "42% (about 3m0s left)" の形式で返します。
+// 処理の対象を所有していない場合など、進み具合が返されない場合は状態だけを返します。
+func describeProcessingProgress(d *youtube.VideoProcessingDetails) string {
+	if d == nil || d.ProcessingProgress == nil || d.ProcessingProgress.PartsTotal == 0 {
+		return "progress unknown"
+	}
+	p := d.ProcessingProgress
+	progress := fmt.Sprintf("%d%%", p.PartsProcessed*100/p.PartsTotal)
+	if p.TimeLeftMs > 0 {
+		left := (time.Duration(p.TimeLeftMs) * time.Millisecond).Round(time.Second)
+		progress += fmt.Sprintf(" (about %v left)", left)
+	}
+	return progress
+}
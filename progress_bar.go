@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// progressBarInterval は、進捗バーを描き直す最短の間隔です。
+const progressBarInterval = 250 * time.Millisecond
+
+// progressBarWidth は、進捗バーの棒の部分の文字数です。
+const progressBarWidth = 30
+
+// isTerminal は、fが端末かどうかを返します。
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// progressBar は、アップロードの進み具合を割合と転送速度のバーとして1行に描き直します。
+// ログを埋めないよう、progressBarInterval より頻繁には描き直しません。
+type progressBar struct {
+	w     io.Writer
+	name  string
+	start time.Time
+
+	mu   sync.Mutex
+	last time.Time
+	// shown は、描き直した行が残っているかどうかです。
+	shown bool
+}
+
+// newProgressBar は、nameのアップロードの進み具合をwに描く progressBar を返します。
+func newProgressBar(w io.Writer, name string) *progressBar {
+	return &progressBar{w: w, name: name, start: time.Now()}
+}
+
+// update は、送信済みのバイト数と全体のバイト数でバーを描き直します。全体が不明な場合は-1です。
+// 前回から progressBarInterval が経っていない場合は、最後の1回を除いて何もしません。
+func (b *progressBar) update(sent, total int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	if sent != total && now.Sub(b.last) < progressBarInterval {
+		return
+	}
+	b.last = now
+
+	rate := ""
+	if elapsed := now.Sub(b.start).Seconds(); elapsed > 0 {
+		rate = formatBytes(int64(float64(sent)/elapsed)) + "/s"
+	}
+	if total <= 0 {
+		fmt.Fprintf(b.w, "\r%s: %s uploaded  %s\033[K", b.name, formatBytes(sent), rate)
+	} else {
+		filled := int(int64(progressBarWidth) * sent / total)
+		if filled > progressBarWidth {
+			filled = progressBarWidth
+		}
+		fmt.Fprintf(b.w, "\r%s [%s%s] %5.1f%%  %s / %s  %s\033[K", b.name,
+			strings.Repeat("=", filled), strings.Repeat(" ", progressBarWidth-filled),
+			float64(sent)*100/float64(total), formatBytes(sent), formatBytes(total), rate)
+	}
+	b.shown = true
+}
+
+// done は、バーの行を終えて以降の表示が同じ行に続かないようにします。
+func (b *progressBar) done() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.shown {
+		fmt.Fprintln(b.w)
+		b.shown = false
+	}
+}
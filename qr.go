@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// authQR は、プロンプトでの認証時に認証URLをQRコードとしても表示するかどうかです。
+// ヘッドレスのサーバーで認証するときに、スマートフォンで読み取って同意画面を開けるようにします。
+var authQR bool
+
+// printAuthQR は、認証URLをターミナルに表示できるQRコードとして出力します。
+// QRコードを生成できない場合は、URLをそのまま出力します。
+func printAuthQR(authURL string) {
+	q, err := qrcode.New(authURL, qrcode.Low)
+	if err != nil {
+		fmt.Printf("Unable to render the authorization URL as a QR code (%v), open it directly:\n%v\n", err, authURL)
+		return
+	}
+	fmt.Println("Or scan this QR code to open the consent page on another device:")
+	fmt.Print(q.ToSmallString(false))
+}
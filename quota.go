@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// dailyQuota は、プロジェクトに既定で割り当てられる1日のクォータです。
+const dailyQuota = 10000
+
+// quotaLocation は、クォータがリセットされる日付の区切りに使うタイムゾーンです。
+// YouTube Data APIのクォータは太平洋時間の午前0時にリセットされます。
+var quotaLocation = func() *time.Location {
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		return time.FixedZone("PST", -8*60*60)
+	}
+	return loc
+}()
+
+// runQuota は、quota のサブコマンドを実行します。現在は report だけに対応しています。
+func runQuota(args []string) error {
+	if len(args) == 0 || args[0] != "report" {
+		return fmt.Errorf("usage: quota report [-days N]")
+	}
+	return runQuotaReport(args[1:])
+}
+
+// runQuotaReport は、結果ログに記録された操作の見積もりのクォータを、日付と操作ごとに集計して表示します。
+// 日付はクォータがリセットされる太平洋時間で区切ります。
+// 結果ログには成功した書き込みの操作だけが記録されるため、一覧の取得などで使った分は含まれません。
+func runQuotaReport(args []string) error {
+	fs := flag.NewFlagSet("quota report", flag.ExitOnError)
+	days := fs.Int("days", 7, "Number of days to report, including today")
+	out := newCommandOutput(fs, "quota report")
+	fs.Parse(args)
+	out.start()
+
+	result, err := quotaReport(*days)
+	return out.finish(result, err)
+}
+
+// quotaDay は、quota report の -json で出力する1日分の集計です。
+type quotaDay struct {
+	Date       string                    `json:"date"`
+	Units      int                       `json:"units"`
+	Operations map[string]quotaOperation `json:"operations"`
+}
+
+// quotaOperation は、1日分の集計のうち1つの操作の回数とクォータです。
+type quotaOperation struct {
+	Calls int `json:"calls"`
+	Units int `json:"units"`
+}
+
+// quotaReport は、今日を含む直近days日分のクォータを集計して表示し、日付順に返します。
+func quotaReport(days int) ([]quotaDay, error) {
+	if days < 1 {
+		return nil, fmt.Errorf("invalid -days %d, must be at least 1", days)
+	}
+
+	now := time.Now().In(quotaLocation)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, quotaLocation)
+	since := today.AddDate(0, 0, -(days - 1))
+	entries, err := readResults(since)
+	if err != nil {
+		return nil, err
+	}
+
+	// 日付ごと、操作ごとの合計
+	byDate := make(map[string]*quotaDay)
+	for _, entry := range entries {
+		date := entry.Time.In(quotaLocation).Format("2006-01-02")
+		day := byDate[date]
+		if day == nil {
+			day = &quotaDay{Date: date, Operations: make(map[string]quotaOperation)}
+			byDate[date] = day
+		}
+		op := day.Operations[entry.Operation]
+		op.Calls++
+		op.Units += entry.Quota
+		day.Operations[entry.Operation] = op
+		day.Units += entry.Quota
+	}
+	report := make([]quotaDay, 0, len(byDate))
+	for _, day := range byDate {
+		report = append(report, *day)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Date < report[j].Date })
+	if len(report) == 0 {
+		fmt.Printf("No operations recorded since %s\n", since.Format("2006-01-02"))
+		return report, nil
+	}
+
+	for _, day := range report {
+		ops := make([]string, 0, len(day.Operations))
+		for op := range day.Operations {
+			ops = append(ops, op)
+		}
+		sort.Strings(ops)
+		fmt.Printf("%s (Pacific Time): %d of %d units (%.0f%%)\n", day.Date, day.Units, dailyQuota, float64(day.Units)*100/dailyQuota)
+		for _, op := range ops {
+			fmt.Printf("  %-22s %4d calls %6d units\n", op, day.Operations[op].Calls, day.Operations[op].Units)
+		}
+	}
+	return report, nil
+}
+
+// quotaReasons は、クォータやレート制限を超えたことを表すAPIのエラーの理由です。
+var quotaReasons = map[string]bool{
+	"quotaExceeded":         true,
+	"dailyLimitExceeded":    true,
+	"rateLimitExceeded":     true,
+	"userRateLimitExceeded": true,
+}
+
+// quotaError は、クォータやレート制限を超えて操作が拒否されたことを表すエラーです。
+// 生のAPIのエラーだけでは次に何をすればよいか分からないため、リセットの時刻や操作の見積もりを添えます。
+type quotaError struct {
+	operation string
+	reason    string
+	err       error
+}
+
+func (e *quotaError) Error() string {
+	cost := quotaCosts[e.operation]
+	if e.reason == "rateLimitExceeded" || e.reason == "userRateLimitExceeded" {
+		return fmt.Sprintf("%s was rate limited (%s): too many requests in a short time, "+
+			"wait a few minutes and run it again, or lower -concurrency: %v", e.operation, e.reason, e.err)
+	}
+	reset := nextQuotaReset(time.Now())
+	return fmt.Sprintf("%s was rejected because the project's daily quota is exhausted (%s). "+
+		"The quota resets at midnight Pacific Time (%s, in %v). "+
+		"This operation costs about %d units and a video upload about %d of the default %d units per day; "+
+		"run \"quota report\" to see recorded usage or request more quota in the Google Cloud Console: %v",
+		e.operation, e.reason, reset.Local().Format("2006-01-02 15:04 MST"), time.Until(reset).Round(time.Minute),
+		cost, quotaCosts["videos.insert"], dailyQuota, e.err)
+}
+
+func (e *quotaError) Unwrap() error {
+	return e.err
+}
+
+// nextQuotaReset は、nowの後で最初にクォータがリセットされる時刻を返します。
+func nextQuotaReset(now time.Time) time.Time {
+	now = now.In(quotaLocation)
+	return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, quotaLocation)
+}
+
+// withQuotaHint は、errがクォータやレート制限によるAPIのエラーであれば、operationの見積もりを添えた *quotaError を返します。
+// それ以外のエラーはそのまま返します。
+func withQuotaHint(operation string, err error) error {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	for _, e := range apiErr.Errors {
+		if quotaReasons[e.Reason] {
+			return &quotaError{operation: operation, reason: e.Reason, err: err}
+		}
+	}
+	return err
+}
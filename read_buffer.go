@@ -0,0 +1,28 @@
+package main
+
+import "io"
+
+// defaultReadBuffer は、動画ファイルから1回に読み込むバイト数の既定値です。
+//
+// アップロードは defaultChunkSize ごとに送信しますが、ファイルからの読み込みは
+// このサイズに区切って行います。ローカルのディスクでは64KiB以上であれば差はほとんどありませんが、
+// NFSやSMBなどのネットワークファイルシステムでは読み込み1回ごとに往復の遅延がかかるため、
+// 大きくするほどスループットが上がります。一方、FUSEでマウントしたオブジェクトストレージなど
+// 大きな読み込みで待たされるバックエンドでは、小さくしたほうが安定することがあります。
+// 既定値は、ネットワークファイルシステムでも往復の回数が十分少なくなる4MiBです。
+const defaultReadBuffer = 4 << 20
+
+// readSizeReader は、下位のReaderへの読み込み1回あたりのバイト数を size までに制限します。
+// 読み込み先のバッファがどれだけ大きくても、-read-buffer のサイズで読み込むようにするために使います。
+// sizeが0の場合は制限しません。
+type readSizeReader struct {
+	r    io.Reader
+	size int
+}
+
+func (r readSizeReader) Read(p []byte) (int, error) {
+	if r.size > 0 && len(p) > r.size {
+		p = p[:r.size]
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// BenchmarkReadSizeReader は、-read-buffer の大きさごとに、動画ファイルをチャンク単位で読み込む速さを測ります。
+func BenchmarkReadSizeReader(b *testing.B) {
+	const fileSize = 64 << 20
+	path := filepath.Join(b.TempDir(), "video.mp4")
+	if err := os.WriteFile(path, make([]byte, fileSize), 0o600); err != nil {
+		b.Fatal(err)
+	}
+	chunk := make([]byte, defaultChunkSize)
+	for _, size := range []int{64 << 10, 1 << 20, defaultReadBuffer, 16 << 20} {
+		b.Run(fmt.Sprintf("%dKiB", size>>10), func(b *testing.B) {
+			b.SetBytes(fileSize)
+			for i := 0; i < b.N; i++ {
+				f, err := os.Open(path)
+				if err != nil {
+					b.Fatal(err)
+				}
+				r := readSizeReader{r: f, size: size}
+				for {
+					if _, err := io.ReadFull(r, chunk); err != nil {
+						if err == io.EOF || err == io.ErrUnexpectedEOF {
+							break
+						}
+						b.Fatal(err)
+					}
+				}
+				f.Close()
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io"
+	"regexp"
+)
+
+// noRedact は、ログや出力に含まれる資格情報を伏せずにそのまま出力するかどうかです。
+// ローカルでのデバッグ以外では有効にしないでください。
+var noRedact bool
+
+// redacted は、伏せた値の代わりに出力する文字列です。
+const redacted = "REDACTED"
+
+// secretPatterns は、伏せる値に一致する正規表現と、その置き換え方です。
+// URLのクエリやフォーム、JSONの項目、Googleのトークンの形式に一致するものを伏せます。
+// アップロードセッションのupload_idは、それだけで動画を送信できるため資格情報として扱います。
+var secretPatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`\b(access_token|refresh_token|client_secret|id_token|code|state|upload_id)=[^&\s"']+`), "${1}=" + redacted},
+	{regexp.MustCompile(`("(?:access_token|refresh_token|client_secret|id_token|code)"\s*:\s*)"[^"]*"`), `${1}"` + redacted + `"`},
+	{regexp.MustCompile(`(?i)\b(Bearer)\s+[\w.~+/-]+=*`), "${1} " + redacted},
+	{regexp.MustCompile(`\bya29\.[\w-]+`), redacted},
+	{regexp.MustCompile(`\b1//[\w-]+`), redacted},
+	{regexp.MustCompile(`\bGOCSPX-[\w-]+`), redacted},
+}
+
+// redact は、s に含まれるアクセストークン、リフレッシュトークン、クライアントシークレット、
+// 認証コードなどを伏せた文字列を返します。-no-redact が指定された場合はそのまま返します。
+func redact(s string) string {
+	if noRedact {
+		return s
+	}
+	for _, p := range secretPatterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+	return s
+}
+
+// redactErr は、エラーのメッセージを redact した文字列を返します。
+func redactErr(err error) string {
+	return redact(err.Error())
+}
+
+// redactingWriter は、書き込まれた内容を redact してから w に書き込みます。
+// logパッケージの出力先に設定して、すべてのログを伏せるために使います。
+type redactingWriter struct {
+	w io.Writer
+}
+
+func (rw redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(rw.w, redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// quotaCosts は、操作ごとに消費するクォータの単位数の見積もりです。
+// https://developers.google.com/youtube/v3/determine_quota_cost の値に基づきます。
+var quotaCosts = map[string]int{
+	"videos.insert":        1600,
+	"videos.update":        50,
+	"videos.delete":        50,
+	"playlistItems.insert": 50,
+	"thumbnails.set":       50,
+	"captions.insert":      400,
+}
+
+// resultEntry は、結果ログに1行ずつ記録する操作の結果です。
+type resultEntry struct {
+	Time      time.Time `json:"time"`
+	Operation string    `json:"operation"`
+	VideoID   string    `json:"video_id,omitempty"`
+	Quota     int       `json:"quota"`
+}
+
+// resultsLogFile は、結果ログのパスを返します。
+func resultsLogFile() (string, error) {
+	return configPath("logs", "results.jsonl")
+}
+
+// recordResult は、成功した操作とその見積もりのクォータを結果ログに追記します。
+// 記録に失敗しても操作自体は成功しているため、警告を表示するだけにします。
+func recordResult(operation, videoID string) {
+	entry := resultEntry{
+		Time:      time.Now().UTC(),
+		Operation: operation,
+		VideoID:   videoID,
+		Quota:     quotaCosts[operation],
+	}
+	if err := appendResult(entry); err != nil {
+		fmt.Printf("Unable to write results log: %v\n", err)
+	}
+}
+
+// resultsLogMu は、同時に実行しているアップロードの結果ログへの追記を直列化します。
+var resultsLogMu sync.Mutex
+
+// appendResult は、結果ログに1件追記します。
+func appendResult(entry resultEntry) error {
+	resultsLogMu.Lock()
+	defer resultsLogMu.Unlock()
+	path, err := resultsLogFile()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(entry)
+}
+
+// readResults は、結果ログのうちsince以降の記録を読み込みます。
+// 結果ログがない場合は空の一覧を返します。壊れた行は読み飛ばします。
+func readResults(since time.Time) ([]resultEntry, error) {
+	path, err := resultsLogFile()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []resultEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry resultEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if !entry.Time.Before(since) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/youtube/v3"
+)
+
+// maxChunkRetries は、1つのチャンクの送信が一時的なエラーで失敗したときに再試行する回数です。
+const maxChunkRetries = 3
+
+// chunkRetryBackoff は、チャンクの最初の再試行までの待ち時間です。再試行のたびに2倍にします。
+// テストで待ち時間を短くできるよう、変数にしています。
+var chunkRetryBackoff = time.Second
+
+// defaultChunkSize は、再開可能アップロードで1回のリクエストに送るバイト数です。
+// 最後のチャンク以外は256KiBの倍数でなければなりません。
+const defaultChunkSize = googleapi.DefaultUploadChunkSize
+
+// セッション作成とチャンク送信では、1回のリクエストにかかる時間の傾向が大きく異なります。
+// セッション作成はメタデータを送るだけですが、サーバー側の検証で待たされることがあり、
+// チャンク送信は回線の速さに応じて defaultChunkSize バイトを送り切るまでの時間がかかります。
+const (
+	// defaultSessionTimeout は、セッション作成のリクエスト1回にかける時間の既定値です。
+	defaultSessionTimeout = time.Minute
+	// defaultChunkTimeout は、チャンク送信と状態の問い合わせのリクエスト1回にかける時間の既定値です。
+	defaultChunkTimeout = 10 * time.Minute
+	// defaultStallTimeout は、チャンクの送信中に進みがないまま待つ時間の既定値です。
+	// 通信がエラーにならずに止まる場合(TCPのブラックホールなど)に、defaultChunkTimeout より早く検出します。
+	defaultStallTimeout = time.Minute
+)
+
+// resumableSession は、YouTubeの再開可能アップロードのセッションです。
+// セッション作成時にメタデータを送信し、動画のバイト列はその後チャンクごとに送信します。
+type resumableSession struct {
+	client *http.Client
+	// URI は、セッション作成時にLocationヘッダーで返されたセッションのURIです。
+	URI string
+	// size は、動画全体のバイト数です。不明な場合は-1です。
+	size      int64
+	chunkSize int
+	// chunkTimeout は、チャンク送信のリクエスト1回にかける時間の上限です。0の場合は制限しません。
+	chunkTimeout time.Duration
+	// stallTimeout は、チャンクの送信中にバイト列の送信もレスポンスもないまま待つ時間の上限です。
+	// 超えた場合はそのリクエストを取り消し、同じセッションで再試行します。0の場合は監視しません。
+	stallTimeout time.Duration
+	// progress は、チャンクがサーバーに受け取られるたびに、受け取られたバイト数と全体のバイト数で呼び出されます。
+	// 全体のバイト数が不明な場合は-1です。nilの場合は呼び出しません。
+	progress func(sent, total int64)
+	// transfer は、チャンクの送信中にバイト列を送るたびに、送信済みの位置と全体のバイト数で呼び出されます。
+	// サーバーに受け取られる前の位置のため、再試行すると戻ることがあります。nilの場合は呼び出しません。
+	transfer func(sent, total int64)
+}
+
+// fieldError は、APIがメタデータの特定の項目を拒否した理由です。
+type fieldError struct {
+	Reason   string `json:"reason"`
+	Message  string `json:"message"`
+	Location string `json:"location"`
+}
+
+// metadataError は、セッション作成のリクエストでメタデータが拒否されたことを表すエラーです。
+// この時点では動画のバイト列はまだ送信されていません。
+type metadataError struct {
+	err    *googleapi.Error
+	fields []fieldError
+}
+
+func (e *metadataError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "video metadata rejected before upload (HTTP %d)", e.err.Code)
+	if len(e.fields) == 0 {
+		fmt.Fprintf(&b, ": %s", e.err.Message)
+	}
+	for _, f := range e.fields {
+		b.WriteString("\n  ")
+		if f.Location != "" {
+			fmt.Fprintf(&b, "%s: ", f.Location)
+		}
+		fmt.Fprintf(&b, "%s: %s", f.Reason, f.Message)
+	}
+	return b.String()
+}
+
+func (e *metadataError) Unwrap() error {
+	return e.err
+}
+
+// newMetadataError は、セッション作成時のAPIエラーから項目ごとのエラーを取り出します。
+func newMetadataError(err *googleapi.Error) *metadataError {
+	var reply struct {
+		Error struct {
+			Errors []fieldError `json:"errors"`
+		} `json:"error"`
+	}
+	json.Unmarshal([]byte(err.Body), &reply)
+	return &metadataError{err: err, fields: reply.Error.Errors}
+}
+
+// videoContentType は、ファイル名の拡張子から動画のContent-Typeを推測します。
+func videoContentType(name string) string {
+	if t := mime.TypeByExtension(filepath.Ext(name)); strings.HasPrefix(t, "video/") {
+		return t
+	}
+	return "video/*"
+}
+
+// startResumableSession は、動画のメタデータ全体を送信して再開可能アップロードのセッションを作成します。
+// メタデータに誤りがある場合は、動画のバイト列を送信する前に *metadataError を返します。
+// sizeが不明な場合は-1を指定します。queryはリクエストに追加するパラメーターです。
+// timeoutは、このリクエストにかける時間の上限です。0の場合は制限しません。
+func startResumableSession(ctx context.Context, client *http.Client, service *youtube.Service,
+	video *youtube.Video, parts []string, contentType string, size int64, query url.Values,
+	timeout time.Duration) (*resumableSession, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	body, err := json.Marshal(video)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	for k, v := range query {
+		params[k] = v
+	}
+	params.Set("alt", "json")
+	params.Set("uploadType", "resumable")
+	params.Set("part", strings.Join(parts, ","))
+	urls := googleapi.ResolveRelative(service.BasePath, "/upload/youtube/v3/videos") + "?" + params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, urls, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("X-Upload-Content-Type", contentType)
+	if size >= 0 {
+		req.Header.Set("X-Upload-Content-Length", strconv.FormatInt(size, 10))
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		if timeout > 0 && errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("creating the upload session timed out after %v (-session-timeout)", timeout)
+		}
+		return nil, err
+	}
+	defer res.Body.Close()
+	if err := googleapi.CheckResponse(res); err != nil {
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusBadRequest {
+			return nil, newMetadataError(apiErr)
+		}
+		return nil, err
+	}
+
+	location := res.Header.Get("Location")
+	if location == "" {
+		return nil, fmt.Errorf("resumable session response has no Location header")
+	}
+	return &resumableSession{
+		client:    client,
+		URI:       location,
+		size:      size,
+		chunkSize: defaultChunkSize,
+	}, nil
+}
+
+// upload は、rから読み込んだ動画のバイト列をチャンクごとに送信します。
+// rはoffsetの位置から読み込める状態でなければなりません。新しいセッションではoffsetは0です。
+// サーバーが受け取ったバイト数が送信したバイト数より少ない場合は、残りを次のチャンクで再送します。
+// 一時的なエラーで失敗したチャンクは、待ち時間を倍にしながら maxChunkRetries 回まで再試行します。
+// シグナルで終了が要求された場合は、送信中のチャンクの完了後に errInterrupted を返します。
+// アップロードが完了すると、作成された動画のリソースを返します。
+func (s *resumableSession) upload(ctx context.Context, r io.Reader, offset int64) (*youtube.Video, error) {
+	buf := make([]byte, 0, s.chunkSize)
+	eof := false
+	retries := 0
+	for {
+		// バッファをチャンクサイズまで埋める
+		if !eof && len(buf) < cap(buf) {
+			n, err := io.ReadFull(r, buf[len(buf):cap(buf)])
+			buf = buf[:len(buf)+n]
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				eof = true
+			} else if err != nil {
+				return nil, err
+			}
+		}
+
+		total := s.size
+		if eof {
+			total = offset + int64(len(buf))
+		}
+		video, acked, err := s.putChunk(ctx, buf, offset, total)
+		if err != nil {
+			failed := fmt.Sprintf("Chunk at offset %d failed", offset)
+			for err != nil {
+				if !isRetryableUploadError(err) || retries >= maxChunkRetries {
+					return nil, err
+				}
+				retries++
+				fmt.Printf("%s, checking session status before retrying (%d/%d): %v\n",
+					failed, retries, maxChunkRetries, redactErr(err))
+				if err := sleepContext(ctx, chunkRetryBackoff<<(retries-1)); err != nil {
+					return nil, err
+				}
+				// 最後のチャンクはサーバー側で完了していても応答だけが失われた可能性があるため、
+				// 再送する前にセッションの状態を確認し、完了していれば動画のリソースをそのまま使う。
+				// 問い合わせも一時的なエラーで失敗した場合は、再試行の1回として数えて問い合わせ直す
+				video, acked, err = s.putChunk(ctx, nil, 0, total)
+				failed = "Session status query failed"
+			}
+		} else {
+			retries = 0
+		}
+		if video != nil {
+			return video, nil
+		}
+		if acked < offset || acked > offset+int64(len(buf)) {
+			return nil, fmt.Errorf("server acknowledged %d bytes, outside of the chunk sent at offset %d", acked, offset)
+		}
+		if eof && acked == offset+int64(len(buf)) {
+			return nil, fmt.Errorf("server did not complete the upload after receiving all %d bytes", acked)
+		}
+		buf = buf[:copy(buf, buf[acked-offset:])]
+		offset = acked
+		if s.progress != nil {
+			s.progress(offset, s.size)
+		}
+		// 終了が要求されていれば、受け取られたチャンクの後で止める。セッションは再開できる
+		if isShutdownRequested() {
+			return nil, errInterrupted
+		}
+	}
+}
+
+// status は、セッションでサーバーがこれまでに受け取ったバイト数を問い合わせます。
+// アップロードがすでに完了している場合は、作成された動画のリソースを返します。
+func (s *resumableSession) status(ctx context.Context) (*youtube.Video, int64, error) {
+	return s.putChunk(ctx, nil, 0, s.size)
+}
+
+// putChunk は、offsetから始まるチャンクを1回のPUTリクエストで送信します。
+// totalが不明な場合は-1を指定します。
+// アップロードが完了した場合は動画のリソースを、途中の場合はサーバーが受け取ったバイト数を返します。
+// chunkTimeout を超えた場合は、再試行できるエラーを返します。
+func (s *resumableSession) putChunk(ctx context.Context, chunk []byte, offset, total int64) (*youtube.Video, int64, error) {
+	parent := ctx
+	if s.chunkTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.chunkTimeout)
+		defer cancel()
+	}
+	video, acked, err := s.doPutChunk(ctx, chunk, offset, total)
+	if err != nil && parent.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+		return nil, 0, fmt.Errorf("chunk at offset %d timed out after %v (-chunk-timeout)", offset, s.chunkTimeout)
+	}
+	return video, acked, err
+}
+
+// doPutChunk は、putChunk のリクエストを送信してレスポンスを解釈します。
+// stallTimeout の間バイト列の送信が進まずレスポンスもない場合は、リクエストを取り消して再試行できるエラーを返します。
+func (s *resumableSession) doPutChunk(ctx context.Context, chunk []byte, offset, total int64) (video *youtube.Video, acked int64, err error) {
+	var body io.Reader = bytes.NewReader(chunk)
+	var watchdog *time.Timer
+	if s.stallTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		var stalled atomic.Bool
+		watchdog = time.AfterFunc(s.stallTimeout, func() {
+			stalled.Store(true)
+			cancel()
+		})
+		defer watchdog.Stop()
+		defer func() {
+			if stalled.Load() {
+				err = fmt.Errorf("chunk at offset %d stalled with no progress for %v (-stall-timeout)", offset, s.stallTimeout)
+			}
+		}()
+	}
+	// 送信できたバイトがあるたびに監視の期限を延ばし、送信中の位置を知らせる。
+	// 状態の問い合わせは本文がないため、レスポンスまでの時間だけを監視する
+	if len(chunk) > 0 && (watchdog != nil || s.transfer != nil) {
+		read := offset
+		body = &progressReader{r: body, progress: func(n int) {
+			if watchdog != nil {
+				watchdog.Reset(s.stallTimeout)
+			}
+			read += int64(n)
+			if s.transfer != nil {
+				s.transfer(read, s.size)
+			}
+		}}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.URI, body)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.ContentLength = int64(len(chunk))
+	req.Header.Set("Content-Range", contentRange(offset, int64(len(chunk)), total))
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+	return parseUploadResponse(res)
+}
+
+// progressReader は、読み込まれるたびに読み込んだバイト数でprogressを呼び出すReaderです。
+type progressReader struct {
+	r        io.Reader
+	progress func(n int)
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.progress(n)
+	}
+	return n, err
+}
+
+// printProgress は、nameのアップロードの進み具合を表示する resumableSession.progress を返します。
+func printProgress(name string) func(sent, total int64) {
+	return func(sent, total int64) {
+		if total < 0 {
+			printLocked("%s: %s uploaded\n", name, formatBytes(sent))
+			return
+		}
+		printLocked("%s: %s / %s uploaded (%.1f%%)\n", name, formatBytes(sent), formatBytes(total),
+			float64(sent)*100/float64(total))
+	}
+}
+
+// formatBytes は、バイト数をKiB、MiB、GiBなどの単位で表した文字列に変換します。
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// isRetryableUploadError は、チャンクの送信を再試行すべきエラーかどうかを返します。
+// ネットワークのエラーとサーバー側の5xxエラーは再試行します。
+func isRetryableUploadError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code >= 500
+	}
+	return true
+}
+
+// sleepContext は、dだけ待機します。待機中にctxがキャンセルされた場合はそのエラーを返します。
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// contentRange は、チャンクのContent-Rangeヘッダーの値を組み立てます。
+func contentRange(offset, length, total int64) string {
+	size := "*"
+	if total >= 0 {
+		size = strconv.FormatInt(total, 10)
+	}
+	if length == 0 {
+		return "bytes */" + size
+	}
+	return fmt.Sprintf("bytes %d-%d/%s", offset, offset+length-1, size)
+}
+
+// parseUploadResponse は、チャンク送信に対するレスポンスを解釈します。
+// 308の場合はRangeヘッダーからサーバーが受け取ったバイト数を、
+// 200または201の場合は作成された動画のリソースを返します。
+func parseUploadResponse(res *http.Response) (*youtube.Video, int64, error) {
+	if res.StatusCode == http.StatusPermanentRedirect {
+		// Rangeヘッダーがない場合は、まだ1バイトも受け取っていない
+		rng := res.Header.Get("Range")
+		if rng == "" {
+			return nil, 0, nil
+		}
+		_, last, ok := strings.Cut(strings.TrimPrefix(rng, "bytes="), "-")
+		end, err := strconv.ParseInt(last, 10, 64)
+		if !ok || err != nil {
+			return nil, 0, fmt.Errorf("malformed Range header %q", rng)
+		}
+		return nil, end + 1, nil
+	}
+	if err := googleapi.CheckResponse(res); err != nil {
+		return nil, 0, err
+	}
+	video := &youtube.Video{}
+	if err := json.NewDecoder(res.Body).Decode(video); err != nil {
+		return nil, 0, err
+	}
+	return video, 0, nil
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// shortChunkRetryBackoff は、テストの間だけ chunkRetryBackoff を短くします。
+func shortChunkRetryBackoff(t *testing.T) {
+	t.Helper()
+	backoff := chunkRetryBackoff
+	chunkRetryBackoff = time.Millisecond
+	t.Cleanup(func() { chunkRetryBackoff = backoff })
+}
+
+// isStatusQuery は、リクエストが本文のない状態の問い合わせかどうかを返します。
+func isStatusQuery(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Range"), "bytes */")
+}
+
+func TestUploadUsesCompletedSessionAfterLostResponse(t *testing.T) {
+	shortChunkRetryBackoff(t)
+	var (
+		mu        sync.Mutex
+		dataPuts  int
+		completed bool
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if isStatusQuery(r) {
+			if !completed {
+				w.WriteHeader(http.StatusPermanentRedirect)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"id":"video-id"}`))
+			return
+		}
+		dataPuts++
+		io.ReadAll(r.Body)
+		completed = true
+		// アップロードは完了したが、クライアントには応答が届かない
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conn.Close()
+	}))
+	defer srv.Close()
+
+	data := []byte("video bytes")
+	session := &resumableSession{client: srv.Client(), URI: srv.URL + "/session", size: int64(len(data)), chunkSize: defaultChunkSize}
+	video, err := session.upload(context.Background(), bytes.NewReader(data), 0)
+	if err != nil {
+		t.Fatalf("upload = %v, want the completed video", err)
+	}
+	if video.Id != "video-id" {
+		t.Errorf("video ID = %q, want video-id", video.Id)
+	}
+	if dataPuts != 1 {
+		t.Errorf("video bytes sent %d times, want 1 (no re-upload)", dataPuts)
+	}
+}
+
+func TestUploadResendsStalledChunkOnSameSession(t *testing.T) {
+	shortChunkRetryBackoff(t)
+	var (
+		mu       sync.Mutex
+		dataPuts int
+		paths    []string
+	)
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		if isStatusQuery(r) {
+			mu.Unlock()
+			// 止まったチャンクは1バイトも受け取っていない
+			w.WriteHeader(http.StatusPermanentRedirect)
+			return
+		}
+		dataPuts++
+		first := dataPuts == 1
+		mu.Unlock()
+		if first {
+			// 本文を読まず、応答もしないまま止まる
+			<-release
+			return
+		}
+		io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"video-id"}`))
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	data := []byte("video bytes")
+	session := &resumableSession{
+		client:       srv.Client(),
+		URI:          srv.URL + "/session",
+		size:         int64(len(data)),
+		chunkSize:    defaultChunkSize,
+		stallTimeout: 100 * time.Millisecond,
+	}
+	video, err := session.upload(context.Background(), bytes.NewReader(data), 0)
+	if err != nil {
+		t.Fatalf("upload = %v, want success after resending the stalled chunk", err)
+	}
+	if video.Id != "video-id" {
+		t.Errorf("video ID = %q, want video-id", video.Id)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if dataPuts != 2 {
+		t.Errorf("chunk sent %d times, want 2", dataPuts)
+	}
+	for _, path := range paths {
+		if path != "/session" {
+			t.Errorf("request to %s, want every request on the same session /session", path)
+		}
+	}
+}
+
+func TestUploadRetriesFailedStatusQuery(t *testing.T) {
+	shortChunkRetryBackoff(t)
+	var (
+		mu            sync.Mutex
+		dataPuts      int
+		statusQueries int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if isStatusQuery(r) {
+			statusQueries++
+			// 最初の問い合わせは一時的なエラーで失敗する
+			if statusQueries == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"id":"video-id"}`))
+			return
+		}
+		dataPuts++
+		io.ReadAll(r.Body)
+		// アップロードは完了したが、クライアントには応答が届かない
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conn.Close()
+	}))
+	defer srv.Close()
+
+	data := []byte("video bytes")
+	session := &resumableSession{client: srv.Client(), URI: srv.URL + "/session", size: int64(len(data)), chunkSize: defaultChunkSize}
+	video, err := session.upload(context.Background(), bytes.NewReader(data), 0)
+	if err != nil {
+		t.Fatalf("upload = %v, want the completed video after the status query is retried", err)
+	}
+	if video.Id != "video-id" {
+		t.Errorf("video ID = %q, want video-id", video.Id)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if dataPuts != 1 || statusQueries != 2 {
+		t.Errorf("%d data PUTs and %d status queries, want 1 and 2", dataPuts, statusQueries)
+	}
+}
+
+func TestConfirmResumeKeepsBufferedAnswers(t *testing.T) {
+	// 2つの答えがまとめて届いても、2つ目の質問で2つ目の答えを読めること
+	defer func(r *bufio.Reader) { stdinReader = r }(stdinReader)
+	stdinReader = bufio.NewReader(strings.NewReader("n\ny\n"))
+
+	saved := &savedSession{File: "video.mp4", Size: 100, Created: time.Now()}
+	if confirmResume(saved, 50) {
+		t.Error("first answer \"n\" resumed the upload")
+	}
+	if !confirmResume(saved, 50) {
+		t.Error("second answer \"y\" was lost")
+	}
+}
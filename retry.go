@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// defaultMaxAttempts は、一時的なエラーで失敗したAPIの呼び出しを試みる回数の既定値です。最初の1回を含みます。
+const defaultMaxAttempts = 4
+
+// retryBackoff は、doWithRetry が最初の再試行までに待つ時間です。再試行のたびに2倍にし、ゆらぎを加えます。
+// テストで待ち時間を短くできるよう、変数にしています。
+var retryBackoff = time.Second
+
+// maxRetryAfter は、Retry-Afterヘッダーに従って待つ時間の上限です。
+const maxRetryAfter = 5 * time.Minute
+
+// retryableStatusCodes は、doWithRetry が再試行するHTTPステータスコードです。
+// 400や403などのそれ以外のエラーは、再試行しても結果が変わらないためすぐに返します。
+var retryableStatusCodes = map[int]bool{
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusTooManyRequests:     true,
+}
+
+// isRetryableStatus は、errが retryableStatusCodes のいずれかのAPIエラーかどうかを返します。
+func isRetryableStatus(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && retryableStatusCodes[apiErr.Code]
+}
+
+// retryAfter は、APIエラーのRetry-Afterヘッダーが指定する待ち時間を返します。
+// ヘッダーがない場合や解釈できない場合は0を返します。
+func retryAfter(err error) time.Duration {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return 0
+	}
+	value := apiErr.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	var d time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		d = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(value); err == nil {
+		d = time.Until(at)
+	}
+	if d < 0 {
+		return 0
+	}
+	if d > maxRetryAfter {
+		return maxRetryAfter
+	}
+	return d
+}
+
+// doWithRetry は、fnを実行し、isRetryableStatus のエラーで失敗した場合は最大でmaxAttempts回まで試みます。
+// 待ち時間はRetry-Afterヘッダーがあればそれに従い、なければ指数関数的に延ばしてゆらぎを加えます。
+// maxAttemptsが1以下の場合は再試行しません。再試行した失敗はmetricsに記録します。
+func doWithRetry(ctx context.Context, name string, maxAttempts int, metrics *uploadMetrics, fn func() error) error {
+	backoff := retryBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !isRetryableStatus(err) || attempt >= maxAttempts {
+			return err
+		}
+		metrics.observeRetry(err)
+		wait := retryAfter(err)
+		if wait == 0 {
+			wait = backoff + time.Duration(rand.Int63n(int64(backoff)))
+			backoff *= 2
+		}
+		fmt.Printf("%s failed, retrying in %v (%d/%d): %v\n", name, wait.Round(time.Millisecond), attempt, maxAttempts-1, redactErr(err))
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// roundTripFunc は、関数を http.RoundTripper として使うためのスタブです。
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// stubResponse は、ステータスコードだけを持つ空の応答を返します。
+func stubResponse(r *http.Request, code int) *http.Response {
+	return &http.Response{
+		StatusCode: code,
+		Status:     http.StatusText(code),
+		Header:     http.Header{},
+		Body:       http.NoBody,
+		Request:    r,
+	}
+}
+
+func TestDoWithRetryRecoversFromServiceUnavailable(t *testing.T) {
+	defer func(b time.Duration) { retryBackoff = b }(retryBackoff)
+	retryBackoff = time.Millisecond
+
+	attempts := 0
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		attempts++
+		if attempts <= 2 {
+			return stubResponse(r, http.StatusServiceUnavailable), nil
+		}
+		return stubResponse(r, http.StatusOK), nil
+	})}
+
+	err := doWithRetry(context.Background(), "test call", defaultMaxAttempts, nil, func() error {
+		res, err := client.Get("https://example.com/upload")
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		return googleapi.CheckResponse(res)
+	})
+	if err != nil {
+		t.Fatalf("doWithRetry = %v, want success", err)
+	}
+	if attempts != 3 {
+		t.Errorf("attempts = %d, want 3", attempts)
+	}
+}
+
+func TestDoWithRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	defer func(b time.Duration) { retryBackoff = b }(retryBackoff)
+	retryBackoff = time.Millisecond
+
+	attempts := 0
+	err := doWithRetry(context.Background(), "test call", 2, nil, func() error {
+		attempts++
+		return &googleapi.Error{Code: http.StatusServiceUnavailable, Message: "backend error"}
+	})
+	if err == nil || !strings.Contains(err.Error(), "backend error") {
+		t.Fatalf("doWithRetry = %v, want the last 503", err)
+	}
+	if attempts != 2 {
+		t.Errorf("attempts = %d, want 2", attempts)
+	}
+}
+
+func TestRetrySubOperationStopsWaitingWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	attempts := 0
+	start := time.Now()
+	err := retrySubOperation(ctx, "test step", nil, func() error {
+		attempts++
+		// Retry-Afterで1分待つよう指示される
+		return &googleapi.Error{Code: http.StatusServiceUnavailable, Header: http.Header{"Retry-After": {"60"}}}
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("retrySubOperation = %v, want the context's deadline error", err)
+	}
+	if attempts != 1 {
+		t.Errorf("%d attempts, want 1", attempts)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("retrySubOperation waited %v after the context was done", d)
+	}
+}
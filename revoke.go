@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// oauthRevokeURL は、トークンを無効にするGoogleのエンドポイントです。
+const oauthRevokeURL = "https://oauth2.googleapis.com/revoke"
+
+// runRevoke は、キャッシュされたトークンを無効にしてからキャッシュファイルを削除します。
+// アカウントを切り替えるときや、マシンを廃棄するときに使います。
+func runRevoke(args []string) error {
+	fs := flag.NewFlagSet("revoke", flag.ExitOnError)
+	addAccountFlag(fs)
+	out := newCommandOutput(fs, "revoke")
+	fs.Parse(args)
+	out.start()
+
+	result, err := revoke()
+	return out.finish(result, err)
+}
+
+// revokeResult は、revoke の -json で出力する結果です。
+type revokeResult struct {
+	// File は、削除したトークンのキャッシュファイルです。
+	File string `json:"file"`
+	// AlreadyInvalid は、トークンが取り消し済みまたは期限切れで、エンドポイントが無効なトークンとして拒否したかどうかです。
+	AlreadyInvalid bool `json:"already_invalid,omitempty"`
+}
+
+// revoke は、キャッシュされたトークンを oauthRevokeURL で無効にし、成功した場合はキャッシュファイルを削除します。
+// リフレッシュトークンがあればそれを無効にし、同じ許可で発行されたアクセストークンもまとめて無効にします。
+// トークンがすでに無効な場合も、キャッシュファイルは削除します。
+func revoke() (*revokeResult, error) {
+	cacheFile, err := tokenCacheFile()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get path to cached credential file. %v", err)
+	}
+	tok, err := tokenFromFile(cacheFile)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read cached token %s: %v", cacheFile, err)
+	}
+	token := tok.RefreshToken
+	if token == "" {
+		token = tok.AccessToken
+	}
+
+	client := &http.Client{Transport: newTransport()}
+	res, err := client.PostForm(oauthRevokeURL, url.Values{"token": {token}})
+	if err != nil {
+		return nil, fmt.Errorf("Unable to revoke token: %v", redactErr(err))
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 1<<16))
+	result := &revokeResult{File: cacheFile}
+	switch {
+	case res.StatusCode == http.StatusOK:
+	case res.StatusCode == http.StatusBadRequest && strings.Contains(string(body), "invalid_token"):
+		result.AlreadyInvalid = true
+	default:
+		return nil, fmt.Errorf("Unable to revoke token: %s: %s", res.Status, redact(strings.TrimSpace(string(body))))
+	}
+
+	tokenFileMu.Lock()
+	defer tokenFileMu.Unlock()
+	if err := os.Remove(cacheFile); err != nil {
+		return nil, fmt.Errorf("token was revoked, but the cache file could not be deleted: %v", err)
+	}
+	if result.AlreadyInvalid {
+		fmt.Printf("The token was already revoked or expired, deleted %s\n", cacheFile)
+	} else {
+		fmt.Printf("Revoked the token and deleted %s\n", cacheFile)
+	}
+	return result, nil
+}
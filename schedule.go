@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// parsePublishAt は、-publish-at のRFC3339形式の時刻を解釈します。
+// 空の場合はゼロ値を返します。過去の時刻では公開を予約できないため、nowより前であればエラーを返します。
+func parsePublishAt(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	at, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid -publish-at %q, must be an RFC3339 time such as 2006-01-02T15:04:05+09:00", s)
+	}
+	if !at.After(now) {
+		return time.Time{}, fmt.Errorf("-publish-at %s is in the past", at.Format(time.RFC3339))
+	}
+	return at, nil
+}
+
+// applyPublishAt は、公開の予約時刻が設定されたメタデータのプライバシー設定をprivateにします。
+// APIは、privateの動画にしか公開の予約を受け付けません。
+func applyPublishAt(meta *videoMetadata) {
+	if meta.PublishAt.IsZero() || meta.Privacy == "private" {
+		return
+	}
+	log.Printf("%s: uploading as private instead of %s until it is published at %s",
+		meta.File, meta.Privacy, meta.PublishAt.Format(time.RFC3339))
+	meta.Privacy = "private"
+}
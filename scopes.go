@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/youtube/v3"
+)
+
+// scopeAliases は、-scopes で完全なURLの代わりに指定できるスコープの短い名前です。
+var scopeAliases = map[string]string{
+	"upload":    youtube.YoutubeUploadScope,
+	"readonly":  youtube.YoutubeReadonlyScope,
+	"youtube":   youtube.YoutubeScope,
+	"force-ssl": youtube.YoutubeForceSslScope,
+	"partner":   youtube.YoutubepartnerScope,
+}
+
+// parseScopes は、カンマ区切りのスコープの一覧を解釈します。
+// 各要素は scopeAliases の短い名前か、https://で始まる完全なURLです。
+func parseScopes(s string) ([]string, error) {
+	var scopes []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		scope, ok := scopeAliases[name]
+		if !ok {
+			if !strings.HasPrefix(name, "https://") {
+				return nil, fmt.Errorf("unknown scope %q, use upload, readonly, youtube, force-ssl, partner or a full URL", name)
+			}
+			scope = name
+		}
+		if !containsScope(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("no scopes given")
+	}
+	return scopes, nil
+}
+
+// tokenScopes は、トークンに許可されたスコープを返します。
+// 認可サーバーの応答またはキャッシュにscopeが含まれていない場合はnilを返します。
+func tokenScopes(tok *oauth2.Token) []string {
+	scope, _ := tok.Extra("scope").(string)
+	return strings.Fields(scope)
+}
+
+// withScopes は、許可されたスコープを記録したトークンを返します。scopesが空の場合はtokをそのまま返します。
+func withScopes(tok *oauth2.Token, scopes []string) *oauth2.Token {
+	if len(scopes) == 0 {
+		return tok
+	}
+	return tok.WithExtra(map[string]interface{}{"scope": strings.Join(scopes, " ")})
+}
+
+// missingScopes は、requestedのうちgrantedに含まれないスコープを返します。
+func missingScopes(granted, requested []string) []string {
+	var missing []string
+	for _, scope := range requested {
+		if !containsScope(granted, scope) {
+			missing = append(missing, scope)
+		}
+	}
+	return missing
+}
+
+// unionScopes は、aの後にbのうちaに含まれないスコープを並べた一覧を返します。
+func unionScopes(a, b []string) []string {
+	union := append([]string(nil), a...)
+	return append(union, missingScopes(a, b)...)
+}
+
+// canRead は、scopesにvideos.listなどの読み取りを許可するスコープが含まれているかどうかを返します。
+// uploadのスコープだけでは、アップロードした動画を含めて読み取れません。
+func canRead(scopes []string) bool {
+	for _, scope := range []string{youtube.YoutubeReadonlyScope, youtube.YoutubeScope, youtube.YoutubeForceSslScope} {
+		if containsScope(scopes, scope) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
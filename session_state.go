@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/youtube/v3"
+)
+
+// savedSession は、中断したアップロードを再開するために保存するセッションの情報です。
+// ファイルのサイズと更新日時が変わった場合は、別のファイルとみなして再開しません。
+type savedSession struct {
+	URI     string    `json:"uri"`
+	File    string    `json:"file"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Created time.Time `json:"created"`
+}
+
+// sessionStateFile は、動画ファイルごとのセッション情報を保存するファイルのパスを返します。
+func sessionStateFile(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return configPath("sessions", hex.EncodeToString(sum[:])+".json")
+}
+
+// loadSavedSession は、動画ファイルに対して保存されたセッション情報を読み込みます。
+// 保存されていない場合や、ファイルが保存後に変更された場合はnilを返します。
+func loadSavedSession(path string, info os.FileInfo) *savedSession {
+	stateFile, err := sessionStateFile(path)
+	if err != nil {
+		return nil
+	}
+	f, err := os.Open(stateFile)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	saved := &savedSession{}
+	if err := json.NewDecoder(f).Decode(saved); err != nil {
+		return nil
+	}
+	if saved.Size != info.Size() || !saved.ModTime.Equal(info.ModTime()) {
+		return nil
+	}
+	return saved
+}
+
+// saveSession は、動画ファイルに対するセッション情報を保存します。
+func saveSession(path string, saved savedSession) error {
+	stateFile, err := sessionStateFile(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(stateFile, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(saved)
+}
+
+// removeSavedSession は、動画ファイルに対して保存されたセッション情報を削除します。
+func removeSavedSession(path string) {
+	if stateFile, err := sessionStateFile(path); err == nil {
+		os.Remove(stateFile)
+	}
+}
+
+// isInteractive は、標準入力が端末かどうかを返します。
+func isInteractive() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// stdinReader は、質問への答えを標準入力から読み込む、プロセス全体で共有する bufio.Reader です。
+// 質問ごとに作成すると、先読みした残りの入力が捨てられ、続けて入力した答えが失われます。
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// readAnswer は、stdinReader から1行を読み込み、前後の空白を取り除いて返します。
+func readAnswer() string {
+	answer, _ := stdinReader.ReadString('\n')
+	return strings.TrimSpace(answer)
+}
+
+// confirmResume は、中断したアップロードを再開するかどうかをユーザーに尋ねます。
+// 同時にアップロードしている場合に質問と他の表示が混ざらないよう、答えるまで outputMu を保持します。
+func confirmResume(saved *savedSession, offset int64) bool {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	fmt.Printf("Found an unfinished upload of %s: %d of %d bytes (%.1f%%) sent, started %v ago.\n",
+		saved.File, offset, saved.Size, float64(offset)*100/float64(saved.Size),
+		time.Since(saved.Created).Round(time.Second))
+	fmt.Print("Resume it? [Y/n] ")
+	answer := strings.ToLower(readAnswer())
+	return answer == "" || answer == "y" || answer == "yes"
+}
+
+// resumeSavedSession は、動画ファイルに対して保存されたセッションがあれば、再開するセッションと
+// 再開する位置を返します。noResumeがtrueの場合は再開しません。
+// chunkTimeoutは、再開したセッションでのチャンク送信のリクエスト1回にかける時間の上限です。
+// 対話モードでは再開するかどうかをユーザーに尋ね、そうでない場合は再開します。
+// 前回のアップロードが実際には完了していた場合は、作成された動画のリソースを返します。
+// セッションが期限切れの場合や再開しない場合は、保存された情報を削除してnilを返します。
+func resumeSavedSession(ctx context.Context, client *http.Client, path string, info os.FileInfo,
+	noResume bool, chunkTimeout time.Duration) (*resumableSession, int64, *youtube.Video) {
+	saved := loadSavedSession(path, info)
+	if saved == nil {
+		return nil, 0, nil
+	}
+	if noResume {
+		removeSavedSession(path)
+		return nil, 0, nil
+	}
+	session := &resumableSession{
+		client:       client,
+		URI:          saved.URI,
+		size:         saved.Size,
+		chunkSize:    defaultChunkSize,
+		chunkTimeout: chunkTimeout,
+	}
+	video, offset, err := session.status(ctx)
+	if err != nil {
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && (apiErr.Code == http.StatusNotFound || apiErr.Code == http.StatusGone) {
+			fmt.Printf("Saved upload session for %s has expired, starting over\n", path)
+		} else {
+			fmt.Printf("Unable to query saved upload session for %s, starting over: %v\n", path, redactErr(err))
+		}
+		removeSavedSession(path)
+		return nil, 0, nil
+	}
+	if video != nil {
+		fmt.Printf("Previous upload of %s had already completed\n", path)
+		removeSavedSession(path)
+		return nil, 0, video
+	}
+
+	if isInteractive() && !confirmResume(saved, offset) {
+		removeSavedSession(path)
+		return nil, 0, nil
+	}
+	fmt.Printf("Resuming upload of %s at byte %d of %d\n", path, offset, saved.Size)
+	return session, offset, nil
+}
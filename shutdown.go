@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// exitInterrupted は、シグナルでアップロードを中断した場合の終了コードです。
+// セッションは保存されているため、同じコマンドをもう一度実行すると続きから再開できます。
+const exitInterrupted = 4
+
+// errInterrupted は、シグナルを受け取って送信中のチャンクの完了後にアップロードを止めたことを表すエラーです。
+var errInterrupted = errors.New("upload interrupted by signal, run the same command again to resume")
+
+// shutdownRequested は、最初のSIGTERMまたはSIGINTを受け取ると閉じられるチャネルです。
+var shutdownRequested = make(chan struct{})
+
+// handleShutdownSignals は、SIGTERMとSIGINTを受け取ったときの動作を設定します。
+// 最初のシグナルでは送信中のチャンクを最後まで送ってからアップロードを止め、
+// 2回目のシグナルではすぐに終了します。
+// Kubernetesのポッドの退避のように、猶予期間の後に強制終了される環境でチャンクを無駄にしないためです。
+func handleShutdownSignals() {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		sig := <-signals
+		log.Printf("Received %v, finishing the current chunk before exiting (send it again to exit immediately)", sig)
+		close(shutdownRequested)
+		sig = <-signals
+		log.Printf("Received %v again, exiting immediately", sig)
+		os.Exit(exitInterrupted)
+	}()
+}
+
+// isShutdownRequested は、シグナルによる終了が要求されているかどうかを返します。
+func isShutdownRequested() bool {
+	select {
+	case <-shutdownRequested:
+		return true
+	default:
+		return false
+	}
+}
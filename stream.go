@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// stdinFile は、動画のファイル名として指定すると標準入力から読み込む名前です。
+const stdinFile = "-"
+
+// openVideoFile は、動画のファイルを開きます。nameが"-"の場合は標準入力を返します。
+// パイプなどシークできないストリームの場合もそのまま返し、呼び出し側で通常のファイルかどうかを判定します。
+func openVideoFile(name string) (*os.File, error) {
+	if name == stdinFile {
+		return os.Stdin, nil
+	}
+	return os.Open(name)
+}
+
+// exactLengthReader は、ちょうど declared バイトを読み込むことを確認するReaderです。
+// -content-length でサイズを宣言したストリームが、宣言より短いか長い場合にエラーを返します。
+type exactLengthReader struct {
+	r         io.Reader
+	declared  int64
+	remaining int64
+}
+
+// newExactLengthReader は、rからちょうどdeclaredバイトを読み込む exactLengthReader を返します。
+func newExactLengthReader(r io.Reader, declared int64) *exactLengthReader {
+	return &exactLengthReader{r: r, declared: declared, remaining: declared}
+}
+
+func (r *exactLengthReader) Read(p []byte) (int, error) {
+	if r.remaining == 0 {
+		// 宣言した長さを読み終えた後にデータが残っていないことを確認する
+		var extra [1]byte
+		n, err := io.ReadFull(r.r, extra[:])
+		if n > 0 {
+			return 0, fmt.Errorf("stream is longer than the -content-length of %d bytes", r.declared)
+		}
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.r.Read(p)
+	r.remaining -= int64(n)
+	if err == io.EOF && r.remaining > 0 {
+		return n, fmt.Errorf("stream ended after %d bytes, but -content-length declared %d",
+			r.declared-r.remaining, r.declared)
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+// countingReader は、読み込んだバイト数を数えるReaderです。
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// maxSubOperationRetries は、アップロード後の処理(再生リストへの追加など)を
+// 一時的なエラーで失敗したときに再試行する回数です。
+// 動画本体はすでにアップロード済みのため、チャンクの送信より少なくしています。
+const maxSubOperationRetries = 2
+
+// subOperationBackoff は、アップロード後の処理を再試行するまでの最初の待機時間です。
+// 再試行のたびに2倍にします。
+const subOperationBackoff = 500 * time.Millisecond
+
+// exitPartialSuccess は、動画はアップロードできたが後続の処理だけが失敗した場合の終了コードです。
+// アップロード自体の失敗(1)と区別し、動画を再アップロードせずに後続の処理だけをやり直せるようにします。
+// バッチモードでは、すべての動画をアップロードできた場合に限って使い、1件でもアップロードが失敗すれば1で終了します。
+const exitPartialSuccess = 3
+
+// partialSuccessError は、バッチモードですべての動画をアップロードできたが、
+// 一部の動画で後続の処理が失敗したことを表すエラーです。
+type partialSuccessError struct {
+	failed int
+	total  int
+}
+
+func (e *partialSuccessError) Error() string {
+	return fmt.Sprintf("all %d videos were uploaded, but post-upload steps failed for %d of them", e.total, e.failed)
+}
+
+// subOperationError は、アップロード後の処理のうち、再試行しても失敗したものを表すエラーです。
+type subOperationError struct {
+	videoID string
+	// names は、失敗した処理の名前です。errs と同じ順に並びます。
+	names []string
+	errs  []error
+}
+
+func (e *subOperationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "video %s was uploaded, but %s failed", e.videoID, strings.Join(e.names, ", "))
+	for i, err := range e.errs {
+		fmt.Fprintf(&b, "\n  %s: %v", e.names[i], err)
+	}
+	return b.String()
+}
+
+// isRetryableSubOperationError は、アップロード後の処理を再試行すべきエラーかどうかを返します。
+// ネットワークのエラーとサーバー側の5xxエラー、429は再試行します。
+// 再生リストにすでに含まれている場合など、それ以外のエラーは再試行しても結果が変わりません。
+func isRetryableSubOperationError(err error) bool {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code >= 500 || apiErr.Code == http.StatusTooManyRequests
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// retrySubOperation は、アップロード後の処理fnを実行し、一時的なエラーで失敗した場合は
+// 待機時間を延ばしながら maxSubOperationRetries 回まで再試行します。
+// APIがRetry-Afterヘッダーで待ち時間を指定した場合はそれに従います。再試行した失敗はmetricsに記録します。
+// 待っている間にctxが取り消された場合はそのエラーを返し、シグナルで終了が要求されている場合は再試行しません。
+func retrySubOperation(ctx context.Context, name string, metrics *uploadMetrics, fn func() error) error {
+	backoff := subOperationBackoff
+	for retries := 0; ; retries++ {
+		err := fn()
+		if err == nil || !isRetryableSubOperationError(err) || retries >= maxSubOperationRetries || isShutdownRequested() {
+			return err
+		}
+		metrics.observeRetry(err)
+		wait := retryAfter(err)
+		if wait == 0 {
+			wait = backoff
+			backoff *= 2
+		}
+		fmt.Printf("%s failed, retrying in %v (%d/%d): %v\n", name, wait, retries+1, maxSubOperationRetries, redactErr(err))
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"mime"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// -sync-dir は、ディレクトリ内の動画ファイルをまとめてアップロードします。
+// 前回すべてのアップロードが成功したときに見た最新の更新日時を保存しておき、
+// 次回はそれより新しいファイルだけを対象にします。
+// 保存するのは実行時刻ではなくファイルの更新日時のため、時計のずれの影響を受けません。
+
+// syncState は、ディレクトリごとに保存する同期の状態です。
+type syncState struct {
+	Dir string `json:"dir"`
+	// LastModTime は、前回成功した同期で対象にしたファイルの最新の更新日時です。
+	LastModTime time.Time `json:"last_mod_time"`
+}
+
+// syncStateFile は、ディレクトリごとの同期の状態を保存するファイルのパスを返します。
+func syncStateFile(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return configPath("sync", hex.EncodeToString(sum[:])+".json")
+}
+
+// loadSyncState は、ディレクトリの同期の状態を読み込みます。
+// 一度も同期が成功していない場合は、ゼロ値の状態を返します。
+func loadSyncState(dir string) (syncState, error) {
+	state := syncState{Dir: dir}
+	stateFile, err := syncStateFile(dir)
+	if err != nil {
+		return state, err
+	}
+	f, err := os.Open(stateFile)
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return state, err
+	}
+	defer f.Close()
+	err = json.NewDecoder(f).Decode(&state)
+	return state, err
+}
+
+// saveSyncState は、ディレクトリの同期の状態を保存します。
+func saveSyncState(state syncState) error {
+	stateFile, err := syncStateFile(state.Dir)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(stateFile, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(state)
+}
+
+// scanSyncDir は、dirの直下にある動画ファイルのうち、更新日時がsinceより新しいものを
+// 更新日時の順に返します。sinceがゼロ値の場合はすべての動画ファイルを返します。
+// タイトルは拡張子を除いたファイル名とし、それ以外の項目は空のままにします。
+// 対象にしたファイルの最新の更新日時もあわせて返します。
+func scanSyncDir(dir string, since time.Time) ([]videoMetadata, time.Time, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, since, err
+	}
+	type found struct {
+		path    string
+		modTime time.Time
+	}
+	var files []found
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !strings.HasPrefix(mime.TypeByExtension(filepath.Ext(entry.Name())), "video/") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, since, err
+		}
+		if !info.ModTime().After(since) {
+			continue
+		}
+		files = append(files, found{filepath.Join(dir, entry.Name()), info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	latest := since
+	items := make([]videoMetadata, 0, len(files))
+	for _, f := range files {
+		name := filepath.Base(f.path)
+		items = append(items, videoMetadata{
+			File:  f.path,
+			Title: strings.TrimSuffix(name, filepath.Ext(name)),
+		})
+		latest = f.modTime
+	}
+	return items, latest, nil
+}
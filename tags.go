@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// maxTagsLength は、タグ全体に使える最大の文字数です。
+// 空白を含むタグは引用符で囲まれるため2文字多く数え、タグの間の区切りも1文字と数えます。
+const maxTagsLength = 500
+
+// maxTagLength は、1つのタグに使える最大の文字数です。
+const maxTagLength = 100
+
+// invalidTagChars は、タグに含めるとAPIが拒否する文字です。
+const invalidTagChars = "<>,"
+
+// tagLength は、上限の計算に使うタグ1つの文字数を返します。
+func tagLength(tag string) int {
+	n := len([]rune(tag))
+	if strings.ContainsAny(tag, " \t") {
+		n += 2
+	}
+	return n
+}
+
+// tagsLength は、上限の計算に使うタグ全体の文字数を返します。
+func tagsLength(tags []string) int {
+	n := 0
+	for i, tag := range tags {
+		if i > 0 {
+			n++
+		}
+		n += tagLength(tag)
+	}
+	return n
+}
+
+// isTagsError は、APIがタグを理由にメタデータを拒否したかどうかを返します。
+func isTagsError(err error) bool {
+	var metaErr *metadataError
+	if !errors.As(err, &metaErr) {
+		return false
+	}
+	for _, f := range metaErr.fields {
+		if f.Reason == "invalidTags" {
+			return true
+		}
+	}
+	return false
+}
+
+// describeTagsError は、APIに拒否されたタグのうち問題のあるものを具体的に挙げたエラーを返します。
+func describeTagsError(tags []string) error {
+	var problems []string
+	for _, tag := range tags {
+		switch {
+		case strings.ContainsAny(tag, invalidTagChars):
+			problems = append(problems, fmt.Sprintf("%q contains one of %q", tag, invalidTagChars))
+		case len([]rune(tag)) > maxTagLength:
+			problems = append(problems, fmt.Sprintf("%q is longer than %d characters", tag, maxTagLength))
+		}
+	}
+	if n := tagsLength(tags); n > maxTagsLength {
+		problems = append(problems, fmt.Sprintf("tags total %d characters, over the %d character limit", n, maxTagsLength))
+	}
+	if len(problems) == 0 {
+		return fmt.Errorf("YouTube rejected the tags %q (use -auto-fix-tags to retry with cleaned tags)", tags)
+	}
+	return fmt.Errorf("YouTube rejected the tags (use -auto-fix-tags to retry with cleaned tags):\n  %s",
+		strings.Join(problems, "\n  "))
+}
+
+// fixTags は、タグから使えない文字を取り除き、長すぎるタグを除いたものを返します。
+// 変更した内容はログに出力します。
+func fixTags(tags []string) []string {
+	fixed := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		cleaned := strings.TrimSpace(strings.Map(func(r rune) rune {
+			if strings.ContainsRune(invalidTagChars, r) {
+				return -1
+			}
+			return r
+		}, tag))
+		if cleaned != tag {
+			log.Printf("Tag %q: removed invalid characters, now %q", tag, cleaned)
+		}
+		switch {
+		case cleaned == "":
+			log.Printf("Tag %q: dropped, empty after cleaning", tag)
+		case len([]rune(cleaned)) > maxTagLength:
+			log.Printf("Tag %q: dropped, longer than %d characters", tag, maxTagLength)
+		default:
+			fixed = append(fixed, cleaned)
+		}
+	}
+	return fixed
+}
+
+// truncateTags は、タグ全体が maxTagsLength 以下になるまで、後ろのタグから順に除きます。
+// 後に加えたタグほど優先度が低いものとして扱います。除いたタグはログに出力します。
+func truncateTags(tags []string) []string {
+	n := len(tags)
+	for n > 0 && tagsLength(tags[:n]) > maxTagsLength {
+		n--
+	}
+	if n < len(tags) {
+		log.Printf("Dropped %d tags to fit the %d character limit: %q", len(tags)-n, maxTagsLength, tags[n:])
+	}
+	return tags[:n]
+}
+
+// splitTags は、カンマ区切りのタグの一覧を分割します。前後の空白は除き、空のタグは含めません。
+func splitTags(s string) []string {
+	var tags []string
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
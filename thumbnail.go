@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/youtube/v3"
+)
+
+// thumbnailExtensions は、-auto-thumbnail-sibling で動画と同じ名前の画像を探すときの拡張子です。
+// 先に見つかったものを使います。
+var thumbnailExtensions = []string{".jpg", ".jpeg", ".png", ".JPG", ".JPEG", ".PNG"}
+
+// maxThumbnailSize は、thumbnails.setが受け付ける画像の最大バイト数です。
+const maxThumbnailSize = 2 << 20
+
+// errAutoThumbnailUnsupported は、自動生成サムネイルの選択を要求されたときに返すエラーです。
+// YouTube Data API v3 のthumbnails.setは画像のアップロードのみを受け付け、
+// YouTubeが自動生成した3枚のサムネイルから選ぶ方法は提供されていません。
+var errAutoThumbnailUnsupported = errors.New("the YouTube Data API does not support selecting " +
+	"one of the auto-generated thumbnails; thumbnails.set only accepts an uploaded image, " +
+	"so pick the thumbnail in YouTube Studio instead")
+
+// selectAutoThumbnail は、-select-auto-thumbnail の値を検証します。
+// APIが自動生成サムネイルの選択に対応していないため、指定された場合は常にエラーを返します。
+// アップロード前に呼び出すことで、フラグが黙って無視されるのを防ぎます。
+func selectAutoThumbnail(index int) error {
+	if index == 0 {
+		return nil
+	}
+	if index < 1 || index > 3 {
+		return fmt.Errorf("invalid -select-auto-thumbnail %d, must be 1, 2 or 3", index)
+	}
+	return errAutoThumbnailUnsupported
+}
+
+// findSiblingThumbnail は、動画ファイルと同じディレクトリにある、拡張子だけが異なる画像のパスを返します。
+// 見つからない場合は空文字列を返します。見つかった画像がサムネイルとして使えない場合はエラーを返します。
+func findSiblingThumbnail(videoPath string) (string, error) {
+	base := strings.TrimSuffix(videoPath, filepath.Ext(videoPath))
+	for _, ext := range thumbnailExtensions {
+		path := base + ext
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if err := validateThumbnail(path, info.Size()); err != nil {
+			return "", fmt.Errorf("%s: %v", path, err)
+		}
+		return path, nil
+	}
+	return "", nil
+}
+
+// validateThumbnail は、画像がthumbnails.setで受け付けられる形式と大きさかどうかを検証します。
+// 形式は拡張子ではなくファイルの先頭のバイト列から判定します。
+func validateThumbnail(path string, size int64) error {
+	if size > maxThumbnailSize {
+		return fmt.Errorf("thumbnail is %d bytes, the maximum is %d", size, maxThumbnailSize)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	switch t := http.DetectContentType(head[:n]); t {
+	case "image/jpeg", "image/png":
+		return nil
+	default:
+		return fmt.Errorf("thumbnail must be a JPEG or PNG image, got %s", t)
+	}
+}
+
+// setThumbnail は、pathの画像を動画のサムネイルとしてアップロードします。
+func setThumbnail(service *youtube.Service, videoID, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := service.Thumbnails.Set(videoID).Media(f).Do(); err != nil {
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusForbidden {
+			return fmt.Errorf("setting thumbnail %s: the channel is not allowed to use custom thumbnails, "+
+				"verify it at https://www.youtube.com/verify (run preflight to check): %w", path, err)
+		}
+		return fmt.Errorf("setting thumbnail %s: %w", path, withQuotaHint("thumbnails.set", err))
+	}
+	recordResult("thumbnails.set", videoID)
+	fmt.Printf("Thumbnail set from %s\n", path)
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// parseTemplateVars は、-var で指定された "key=value" の一覧を、テンプレートの変数に変換します。
+func parseTemplateVars(pairs []string) (map[string]string, error) {
+	vars := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid -var %q, expected key=value", pair)
+		}
+		vars[key] = value
+	}
+	return vars, nil
+}
+
+// renderTemplate は、textをGoの text/template として vars で展開します。
+// 定義されていない変数を "<no value>" のまま残さないよう、その場合はエラーを返します。
+func renderTemplate(name, text string, vars map[string]string) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s template: %v", name, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, vars); err != nil {
+		return "", fmt.Errorf("rendering %s template: %v (define it with -var key=value or vars in the manifest)", name, err)
+	}
+	return b.String(), nil
+}
+
+// applyTemplates は、メタデータのタイトルと説明をテンプレートとして展開します。
+// 変数は -var の値に項目ごとの変数を重ね、同じ名前は項目ごとの値を優先します。
+func applyTemplates(meta *videoMetadata, flagVars map[string]string) error {
+	vars := make(map[string]string, len(flagVars)+len(meta.Vars))
+	for key, value := range flagVars {
+		vars[key] = value
+	}
+	for key, value := range meta.Vars {
+		vars[key] = value
+	}
+	var err error
+	if meta.Title, err = renderTemplate("title", meta.Title, vars); err != nil {
+		return err
+	}
+	meta.Description, err = renderTemplate("description", meta.Description, vars)
+	return err
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/youtube/v3"
+)
+
+// oauth2clientTimeFormat は、Pythonのoauth2clientがtoken_expiryに使う時刻の形式です。
+const oauth2clientTimeFormat = "2006-01-02T15:04:05Z"
+
+// credentialsFromToken は、oauth2.Tokenを oAuth2Credentials の形式に変換します。
+// クライアントIDとシークレットはトークンに含まれないため、引数で受け取ります。
+func credentialsFromToken(tok *oauth2.Token, clientID, clientSecret string, scopes []string) oAuth2Credentials {
+	creds := oAuth2Credentials{
+		AccessToken:  tok.AccessToken,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RefreshToken: tok.RefreshToken,
+		TokenURI:     "https://oauth2.googleapis.com/token",
+		RevokeURI:    oauthRevokeURL,
+		Scopes:       scopes,
+		TokenInfoURI: "https://oauth2.googleapis.com/tokeninfo",
+		Invalid:      false,
+		Class:        "OAuth2Credentials",
+		Module:       "oauth2client.client",
+	}
+	creds.TokenResponse.AccessToken = tok.AccessToken
+	creds.TokenResponse.TokenType = tok.Type()
+	creds.TokenResponse.Scope = strings.Join(scopes, " ")
+	if !tok.Expiry.IsZero() {
+		creds.TokenExpiry = tok.Expiry.UTC().Format(oauth2clientTimeFormat)
+		if expiresIn := time.Until(tok.Expiry); expiresIn > 0 {
+			creds.TokenResponse.ExpiresIn = int(expiresIn.Seconds())
+		}
+	}
+	return creds
+}
+
+// token は、oAuth2Credentials をoauth2.Tokenに変換します。
+func (c oAuth2Credentials) token() (*oauth2.Token, error) {
+	tok := &oauth2.Token{
+		AccessToken:  c.AccessToken,
+		TokenType:    c.TokenResponse.TokenType,
+		RefreshToken: c.RefreshToken,
+	}
+	if tok.AccessToken == "" {
+		tok.AccessToken = c.TokenResponse.AccessToken
+	}
+	if c.TokenExpiry != "" {
+		expiry, err := time.Parse(time.RFC3339, c.TokenExpiry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid token_expiry %q: %v", c.TokenExpiry, err)
+		}
+		tok.Expiry = expiry
+	}
+	if tok.AccessToken == "" && tok.RefreshToken == "" {
+		return nil, fmt.Errorf("credentials contain neither an access token nor a refresh token")
+	}
+	scopes := c.Scopes
+	if len(scopes) == 0 {
+		scopes = strings.Fields(c.TokenResponse.Scope)
+	}
+	return withScopes(tok, scopes), nil
+}
+
+// runExportToken は、キャッシュされたトークンをPython互換の oAuth2Credentials 形式で出力します。
+// -json の場合は、資格情報を結果としてJSONの共通の形式で出力します。
+func runExportToken(args []string) error {
+	fs := flag.NewFlagSet("export-token", flag.ExitOnError)
+	output := fs.String("o", "", "File to write the credentials to (default: stdout)")
+	fs.BoolVar(&compactJSON, "compact", false, "Write the credentials as compact single-line JSON")
+	addAccountFlag(fs)
+	out := newCommandOutput(fs, "export-token")
+	fs.Parse(args)
+	out.start()
+
+	creds, err := exportToken()
+	if err != nil || (*output == "" && out.jsonMode()) {
+		return out.finish(creds, err)
+	}
+	if *output == "" {
+		return newFileJSONEncoder(os.Stdout).Encode(creds)
+	}
+	f, err := os.OpenFile(*output, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return out.finish(nil, err)
+	}
+	defer f.Close()
+	if err := newFileJSONEncoder(f).Encode(creds); err != nil {
+		return out.finish(nil, err)
+	}
+	return out.finish(tokenFileResult{File: *output}, nil)
+}
+
+// tokenFileResult は、export-token と import-token の -json で出力する、書き込んだファイルのパスです。
+type tokenFileResult struct {
+	File string `json:"file"`
+}
+
+// exportToken は、キャッシュされたトークンを oAuth2Credentials に変換します。
+func exportToken() (*oAuth2Credentials, error) {
+	cacheFile, err := tokenCacheFile()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get path to cached credential file. %v", err)
+	}
+	tok, err := tokenFromFile(cacheFile)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read cached token %s: %v", cacheFile, err)
+	}
+
+	// クライアント情報はキャッシュに含まれないため、.envまたは環境変数から補う
+	if err := loadDotEnv(); err != nil {
+		return nil, err
+	}
+	// スコープが記録されていない古いキャッシュは、アップロードのスコープで認証されたものとみなす
+	scopes := tokenScopes(tok)
+	if len(scopes) == 0 {
+		scopes = []string{youtube.YoutubeUploadScope}
+	}
+	creds := credentialsFromToken(tok, os.Getenv("YOUTUBE_CLIENT_ID"), os.Getenv("YOUTUBE_CLIENT_SECRET"), scopes)
+	return &creds, nil
+}
+
+// runImportToken は、Python互換の oAuth2Credentials 形式のファイルを読み込み、
+// トークンのキャッシュに保存します。ファイル名に"-"を指定すると標準入力から読み込みます。
+func runImportToken(args []string) error {
+	fs := flag.NewFlagSet("import-token", flag.ExitOnError)
+	fs.BoolVar(&compactJSON, "compact", false, "Write the token cache as compact single-line JSON")
+	addAccountFlag(fs)
+	out := newCommandOutput(fs, "import-token")
+	fs.Parse(args)
+	out.start()
+
+	if fs.NArg() != 1 {
+		return out.finish(nil, fmt.Errorf("usage: import-token <credentials.json|->"))
+	}
+	cacheFile, err := importToken(fs.Arg(0))
+	if err != nil {
+		return out.finish(nil, err)
+	}
+	return out.finish(tokenFileResult{File: cacheFile}, nil)
+}
+
+// importToken は、nameの oAuth2Credentials を読み込んでトークンのキャッシュに保存し、
+// 保存先のパスを返します。
+func importToken(name string) (string, error) {
+	var r io.Reader = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		r = f
+	}
+	var creds oAuth2Credentials
+	if err := json.NewDecoder(r).Decode(&creds); err != nil {
+		return "", fmt.Errorf("Unable to parse credentials: %v", err)
+	}
+	tok, err := creds.token()
+	if err != nil {
+		return "", err
+	}
+
+	cacheFile, err := tokenCacheFile()
+	if err != nil {
+		return "", fmt.Errorf("Unable to get path to cached credential file. %v", err)
+	}
+	if err := saveToken(cacheFile, tok); err != nil {
+		return "", err
+	}
+	return cacheFile, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/oauth2"
+)
+
+// maxTokenSize は、ファイルディスクリプタから読み込むトークンのJSONの最大のバイト数です。
+const maxTokenSize = 1 << 20
+
+// readTokenFD は、開かれたファイルディスクリプタfdからトークンのJSONを読み込みます。
+// トークンが環境変数(/procから見える)やディスクに残らないよう、オーケストレーターから
+// ファイルディスクリプタで渡す場合に使います。読み込んだ後はfdを閉じます。
+func readTokenFD(fd int) (*oauth2.Token, error) {
+	if fd < 0 {
+		return nil, fmt.Errorf("invalid -token-fd %d", fd)
+	}
+	f := os.NewFile(uintptr(fd), fmt.Sprintf("fd %d", fd))
+	if f == nil {
+		return nil, fmt.Errorf("invalid -token-fd %d", fd)
+	}
+	defer f.Close()
+	if _, err := f.Stat(); err != nil {
+		return nil, fmt.Errorf("-token-fd %d is not open: %v", fd, err)
+	}
+	b, err := io.ReadAll(io.LimitReader(f, maxTokenSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading -token-fd %d: %v", fd, err)
+	}
+	if len(b) > maxTokenSize {
+		return nil, fmt.Errorf("-token-fd %d has more than %d bytes", fd, maxTokenSize)
+	}
+	tok := &oauth2.Token{}
+	if err := json.Unmarshal(b, tok); err != nil {
+		return nil, fmt.Errorf("-token-fd %d does not contain token JSON: %v", fd, err)
+	}
+	if tok.AccessToken == "" && tok.RefreshToken == "" {
+		return nil, fmt.Errorf("-token-fd %d contains neither an access token nor a refresh token", fd)
+	}
+	return tok, nil
+}
+
+// staticTokenStore は、あらかじめ読み込んだトークンを返す TokenStore です。
+// 読み込み元に書き戻せないため、Saveはエラーを返します。
+type staticTokenStore struct {
+	tok *oauth2.Token
+}
+
+func (s staticTokenStore) Load() (*oauth2.Token, error) {
+	return s.tok, nil
+}
+
+func (staticTokenStore) Save(*oauth2.Token) error {
+	return fmt.Errorf("the token was read from a file descriptor and cannot be saved")
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// forceIPv4 は、HTTPの接続をIPv4だけで行うかどうかです。
+// GoogleへのIPv6の経路が壊れているネットワークで、アップロードが止まるのを避けるために使います。
+var forceIPv4 bool
+
+// tlsConfig は、-ca-cert と -insecure-skip-verify から作ったTLSの設定です。
+// nilの場合はシステムの設定をそのまま使います。
+var tlsConfig *tls.Config
+
+// configureTLS は、caCertのPEMファイルに含まれるCA証明書をシステムのルート証明書に加えた
+// TLSの設定を作ります。TLSを検査するプロキシの内側からGoogleに接続するために使います。
+// insecureがtrueの場合は証明書を検証しません。最後の手段であるため、大きく警告します。
+func configureTLS(caCert string, insecure bool) error {
+	if caCert == "" && !insecure {
+		return nil
+	}
+	config := &tls.Config{}
+	if caCert != "" {
+		pem, err := os.ReadFile(caCert)
+		if err != nil {
+			return fmt.Errorf("reading -ca-cert: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("-ca-cert %s contains no PEM encoded certificates", caCert)
+		}
+		config.RootCAs = pool
+	}
+	if insecure {
+		log.Println("WARNING: -insecure-skip-verify disables TLS certificate verification. " +
+			"Anyone on the network path can read your OAuth tokens and videos. Use -ca-cert instead if possible.")
+		config.InsecureSkipVerify = true
+	}
+	tlsConfig = config
+	return nil
+}
+
+// newTransport は、APIとトークンのエンドポイントへの接続に使うTransportを生成します。
+// 既定ではhttp.DefaultTransportと同じくデュアルスタックで接続し、システムのルート証明書で検証します。
+func newTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if tlsConfig != nil {
+		t.TLSClientConfig = tlsConfig.Clone()
+	}
+	if forceIPv4 {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "tcp4", addr)
+		}
+	}
+	return t
+}
+
+// withHTTPClient は、トークンの取得と更新にも newTransport を使うよう、
+// oauth2パッケージが参照するHTTPクライアントをctxに設定します。
+// oauth2.NewClientもこのクライアントのTransportを下位に使います。
+func withHTTPClient(ctx context.Context) context.Context {
+	return context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: newTransport()})
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// runUpdate は、アップロード済みの動画のメタデータを、動画を再アップロードせずに更新します。
+// 指定したフラグの項目だけを変更し、それ以外は現在の値を残します。
+func runUpdate(args []string) error {
+	fs := flag.NewFlagSet("update", flag.ExitOnError)
+	title := fs.String("title", "", "New title")
+	description := fs.String("desc", "", "New description")
+	tags := fs.String("tags", "", "New comma-separated tags, replacing the current ones")
+	privacy := fs.String("privacy", "", "New privacy status (public, unlisted or private)")
+	category := fs.String("category", "", "New category ID or name")
+	categoryRegion := fs.String("category-region", defaultCategoryRegion, "Region whose category names -category is resolved against")
+	addAccountFlag(fs)
+	out := newCommandOutput(fs, "update")
+	fs.Parse(args)
+	out.start()
+
+	if fs.NArg() != 1 {
+		return out.finish(nil, fmt.Errorf("usage: update [-title T] [-desc D] [-tags a,b] [-privacy P] [-category C] <video ID>"))
+	}
+	meta := videoMetadata{
+		Title:       *title,
+		Description: *description,
+		Tags:        splitTags(*tags),
+		Privacy:     *privacy,
+	}
+	var err error
+	meta.CategoryID, err = resolveCategory(*category, *categoryRegion)
+	if err != nil {
+		return out.finish(nil, err)
+	}
+	if meta.Privacy != "" && !isPrivacyStatus(meta.Privacy) {
+		return out.finish(nil, fmt.Errorf("invalid -privacy %q, must be one of %s", meta.Privacy, strings.Join(privacyStatuses, ", ")))
+	}
+
+	_, service, err := newService(context.Background(), youtube.YoutubeScope)
+	if err != nil {
+		return out.finish(nil, err)
+	}
+	result, err := updateVideo(service, fs.Arg(0), meta)
+	return out.finish(result, err)
+}
+
+// updateResult は、update の -json で出力する結果です。
+type updateResult struct {
+	ID string `json:"id"`
+	// Parts は、変更があったため送信した部分です。変更がなかった場合は空です。
+	Parts []string `json:"parts"`
+}
+
+// updateVideo は、videoIDの動画の現在のsnippetとstatusを取得し、metaで空でない項目だけを変更して更新します。
+// 変更があった部分だけをVideos.Updateで送り、他の部分の値を上書きしないようにします。
+// 何も変わらない場合はAPIを呼び出しません。
+func updateVideo(service *youtube.Service, videoID string, meta videoMetadata) (*updateResult, error) {
+	parts := []string{"snippet", "status"}
+	current, err := getVideo(service, videoID, parts)
+	if err != nil {
+		return nil, err
+	}
+	// 読み取り専用の項目を除いた、送信できる形の現在の値
+	before := &youtube.Video{Id: videoID}
+	after := &youtube.Video{Id: videoID}
+	for _, part := range parts {
+		copyVideoPart(before, current, part)
+		copyVideoPart(after, current, part)
+	}
+	if after.Snippet != nil {
+		if meta.Title != "" {
+			after.Snippet.Title = meta.Title
+		}
+		if meta.Description != "" {
+			after.Snippet.Description = meta.Description
+		}
+		if len(meta.Tags) > 0 {
+			after.Snippet.Tags = meta.Tags
+		}
+		if meta.CategoryID != "" {
+			after.Snippet.CategoryId = meta.CategoryID
+		}
+	}
+	if after.Status != nil && meta.Privacy != "" {
+		after.Status.PrivacyStatus = meta.Privacy
+	}
+
+	update := &youtube.Video{Id: videoID}
+	var changed []string
+	if !reflect.DeepEqual(before.Snippet, after.Snippet) {
+		update.Snippet = after.Snippet
+		changed = append(changed, "snippet")
+	}
+	if !reflect.DeepEqual(before.Status, after.Status) {
+		update.Status = after.Status
+		changed = append(changed, "status")
+	}
+	result := &updateResult{ID: videoID, Parts: []string{}}
+	if len(changed) == 0 {
+		fmt.Printf("Video %s already has these values, nothing to update\n", videoID)
+		return result, nil
+	}
+	if _, err := service.Videos.Update(changed, update).Do(); err != nil {
+		return nil, fmt.Errorf("updating video %s: %w", videoID, withQuotaHint("videos.update", err))
+	}
+	recordResult("videos.update", videoID)
+	fmt.Printf("Updated %s of video %s\n", strings.Join(changed, ", "), videoID)
+	result.Parts = changed
+	return result, nil
+}
@@ -0,0 +1,135 @@
+// Package upload は、youtube-go の動画のメタデータとアップロードを、ほかのGoのプログラムから使えるようにします。
+//
+// コマンドの youtube-go も同じ VideoMetadata と BuildVideo を使います。コマンドは中断したアップロードの再開や
+// -tee などのために独自の再開可能アップロードを行いますが、このパッケージの Uploader は
+// youtube.Service の機能だけでアップロードするため、サーバーなどに組み込んで使えます。
+package upload
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// VideoMetadata は、アップロードする動画1件分のメタデータです。
+type VideoMetadata struct {
+	// File は、動画ファイルのパスです。Uploader.Upload では使わず、コマンドだけが開きます。
+	File        string
+	Title       string
+	Description string
+	Tags        []string
+	Privacy     string
+	CategoryID  string
+	// Thumbnail は、アップロード後にサムネイルとして設定する画像のパスです。空の場合は設定しません。
+	// Uploader.Upload は設定しません。
+	Thumbnail string
+	// PublishAt は、動画を自動的に公開する時刻です。ゼロ値の場合は予約しません。
+	PublishAt time.Time
+	// Captions は、アップロード後に追加する字幕です。Uploader.Upload は追加しません。
+	Captions []CaptionTrack
+	// DefaultLanguage は、TitleとDescriptionの言語を表すBCP-47の言語コードです。
+	DefaultLanguage string
+	// Localizations は、BCP-47の言語コードごとのタイトルと説明です。
+	Localizations map[string]Localization
+	// MadeForKids は、動画が子ども向けかどうかの宣言です。nilの場合は宣言していません。
+	MadeForKids *bool
+	// RecordingDate は、動画を録画したRFC 3339形式の日時です。空の場合はrecordingDetailsを送りません。
+	RecordingDate string
+	// Vars は、TitleとDescriptionのテンプレートで使う、この動画だけの変数です。
+	Vars map[string]string
+}
+
+// Localization は、1つの言語のタイトルと説明です。
+type Localization struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// CaptionTrack は、字幕ファイルとその言語です。
+type CaptionTrack struct {
+	// Language は、字幕のBCP-47の言語コードです。
+	Language string
+	Path     string
+}
+
+// BuildVideo は、メタデータからVideos.Insertに渡すyoutube.Videoを組み立てます。
+func BuildVideo(meta VideoMetadata) *youtube.Video {
+	video := &youtube.Video{
+		Snippet: &youtube.VideoSnippet{
+			Title:       meta.Title,
+			Description: meta.Description,
+			CategoryId:  meta.CategoryID,
+			// 既定の言語がない場合、APIはローカライズを受け付けない
+			DefaultLanguage: meta.DefaultLanguage,
+		},
+		Status: &youtube.VideoStatus{PrivacyStatus: meta.Privacy},
+	}
+	if len(meta.Localizations) > 0 {
+		video.Localizations = make(map[string]youtube.VideoLocalization, len(meta.Localizations))
+		for language, l := range meta.Localizations {
+			video.Localizations[language] = youtube.VideoLocalization{Title: l.Title, Description: l.Description}
+		}
+	}
+	if meta.MadeForKids != nil {
+		video.Status.SelfDeclaredMadeForKids = *meta.MadeForKids
+		// falseもゼロ値として省略されないよう、明示的に送る
+		video.Status.ForceSendFields = []string{"SelfDeclaredMadeForKids"}
+	}
+	if !meta.PublishAt.IsZero() {
+		video.Status.PublishAt = meta.PublishAt.Format(time.RFC3339)
+	}
+	if meta.RecordingDate != "" {
+		video.RecordingDetails = &youtube.VideoRecordingDetails{RecordingDate: meta.RecordingDate}
+	}
+
+	// APIは、tagsが空文字列の場合、400 Bad Requestレスポンスを返す。
+	if len(meta.Tags) > 0 {
+		video.Snippet.Tags = meta.Tags
+	}
+	return video
+}
+
+// InferParts は、動画のリソースのうち値が設定されている部分の一覧を返します。
+// メタデータに録画日時(RecordingDate)やローカライズが含まれていれば、それらも自動的に含まれます。
+func InferParts(video *youtube.Video) []string {
+	var parts []string
+	if video.Snippet != nil {
+		parts = append(parts, "snippet")
+	}
+	if video.Status != nil {
+		parts = append(parts, "status")
+	}
+	if video.RecordingDetails != nil {
+		parts = append(parts, "recordingDetails")
+	}
+	if len(video.Localizations) > 0 {
+		parts = append(parts, "localizations")
+	}
+	return parts
+}
+
+// Uploader は、認証済みの youtube.Service で動画をアップロードします。
+type Uploader struct {
+	Service *youtube.Service
+}
+
+// NewUploader は、serviceでアップロードする Uploader を作成します。
+func NewUploader(service *youtube.Service) *Uploader {
+	return &Uploader{Service: service}
+}
+
+// Upload は、rから読み込んだバイト列を、metaのメタデータを付けた動画としてアップロードし、
+// 作成された動画のリソースを返します。送信する部分は InferParts でメタデータから決めます。
+// サムネイルと字幕は設定しないため、必要であれば返された動画のIDで別に設定してください。
+// ctxが取り消された場合は、送信中のリクエストを中止してエラーを返します。
+func (u *Uploader) Upload(ctx context.Context, meta VideoMetadata, r io.Reader) (*youtube.Video, error) {
+	video := BuildVideo(meta)
+	response, err := u.Service.Videos.Insert(InferParts(video), video).Media(r).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("uploading %q: %w", meta.Title, err)
+	}
+	return response, nil
+}
@@ -0,0 +1,47 @@
+package upload
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/option"
+	"google.golang.org/api/youtube/v3"
+)
+
+func TestUploaderUpload(t *testing.T) {
+	var part, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		part = strings.Join(r.URL.Query()["part"], ",")
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"video-id"}`))
+	}))
+	defer srv.Close()
+	service, err := youtube.NewService(context.Background(),
+		option.WithHTTPClient(srv.Client()), option.WithEndpoint(srv.URL+"/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meta := VideoMetadata{Title: "title", Privacy: "private", CategoryID: "22", RecordingDate: "2024-05-01T09:00:00Z"}
+	video, err := NewUploader(service).Upload(context.Background(), meta, strings.NewReader("video bytes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if video.Id != "video-id" {
+		t.Errorf("video ID = %q, want video-id", video.Id)
+	}
+	if part != "snippet,status,recordingDetails" {
+		t.Errorf("part = %q, want snippet, status and recordingDetails", part)
+	}
+	for _, want := range []string{`"title":"title"`, `"recordingDate":"2024-05-01T09:00:00Z"`, "video bytes"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("request body does not contain %s:\n%s", want, body)
+		}
+	}
+}
@@ -3,16 +3,17 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
 	"google.golang.org/api/youtube/v3"
-
-	"github.com/joho/godotenv"
 )
 
 type clientSecret struct {
@@ -27,6 +28,8 @@ type clientSecret struct {
 	} `json:"installed"`
 }
 
+// oAuth2Credentials は、Pythonのoauth2clientが保存する資格情報のJSON形式です。
+// export-token と import-token はこの形式でトークンを読み書きします。
 type oAuth2Credentials struct {
 	AccessToken   string  `json:"access_token"`
 	ClientID      string  `json:"client_id"`
@@ -51,10 +54,14 @@ type oAuth2Credentials struct {
 	Module       string   `json:"_module"`
 }
 
+// createClinetSecret は、環境変数からクライアントシークレットのJSONを生成します。
+// redirect_urisには、選ばれている認証フローのリダイレクトURIだけを含めます。
+// .envがなくても、必要な環境変数が設定されていれば生成します。
 func createClinetSecret() ([]byte, error) {
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatal("Error loading .env file")
+	if err := loadDotEnv(); err != nil {
+		return nil, err
+	}
+	if err := requireEnv("YOUTUBE_CLIENT_ID", "YOUTUBE_CLIENT_SECRET"); err != nil {
 		return nil, err
 	}
 	clientData := clientSecret{
@@ -73,27 +80,34 @@ func createClinetSecret() ([]byte, error) {
 			TokenUri:                "https://oauth2.googleapis.com/token",
 			AuthProviderX509CertUrl: "https://www.googleapis.com/oauth2/v1/certs",
 			ClientSecret:            os.Getenv("YOUTUBE_CLIENT_SECRET"),
-			RedirectUris:            []string{"http://localhost"},
+			RedirectUris:            []string{redirectURL()},
 		},
 	}
 	return json.Marshal(clientData)
 }
 
+// createOAuth2 は、環境変数に設定されたトークンから oAuth2Credentials のJSONを生成します。
+// .envがなくても、トークンの環境変数が設定されていれば生成します。
 func createOAuth2() ([]byte, error) {
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatal("Error loading .env file")
+	if err := loadDotEnv(); err != nil {
 		return nil, err
 	}
+	// アクセストークンだけでも使えるため、どちらもない場合だけ更新に必要なリフレッシュトークンを求める
+	accessToken := getenv("YOUTUBE_ACCESS_TOKEN")
+	if accessToken == "" {
+		if err := requireEnv("YOUTUBE_REFRESH_TOKEN"); err != nil {
+			return nil, err
+		}
+	}
 	oauth2Data := oAuth2Credentials{
-		AccessToken:  os.Getenv("YOOUTUBE_ACCESS_TOKEN"),
+		AccessToken:  accessToken,
 		ClientID:     os.Getenv("YOUTUBE_CLIENT_ID"),
 		ClientSecret: os.Getenv("YOUTUBE_CLIENT_SECRET"),
 		RefreshToken: os.Getenv("YOUTUBE_REFRESH_TOKEN"),
 		TokenExpiry:  os.Getenv("YOUTUBE_TOKEN_EXPIRY"),
 		TokenURI:     "https://oauth2.googleapis.com/token",
 		UserAgent:    nil,
-		RevokeURI:    "https://oauth2.googleapis.com/revoke",
+		RevokeURI:    oauthRevokeURL,
 		IDToken:      nil,
 		IDTokenJWT:   nil,
 		TokenResponse: struct {
@@ -102,7 +116,7 @@ func createOAuth2() ([]byte, error) {
 			Scope       string `json:"scope"`
 			TokenType   string `json:"token_type"`
 		}{
-			AccessToken: os.Getenv("YOUTUBE_ACCESS_TOKEN"),
+			AccessToken: accessToken,
 			ExpiresIn:   3599,
 			Scope:       "https://www.googleapis.com/auth/youtube.upload",
 			TokenType:   "Bearer",
@@ -116,71 +130,660 @@ func createOAuth2() ([]byte, error) {
 	return json.Marshal(oauth2Data)
 }
 
-func getToken() (*oauth2.Token, error) {
-	f, err := createOAuth2()
+// envTokenStore は、.envまたは環境変数に設定された資格情報からトークンを読み込む TokenStore です。
+// 環境変数には書き戻せないため、更新したトークンはトークンのキャッシュファイルに保存し、
+// 次回からはキャッシュファイルがあればそちらを優先して読み込みます。
+// -account が指定された場合は、環境変数のトークンが別のアカウントのものである可能性があるため、
+// そのアカウントのキャッシュファイルだけを読み込みます。
+type envTokenStore struct{}
+
+func (envTokenStore) Load() (*oauth2.Token, error) {
+	cache, err := cacheTokenStore()
+	if account != "" {
+		if err != nil {
+			return nil, err
+		}
+		return cache.Load()
+	}
+	if err == nil {
+		if tok, err := cache.Load(); err == nil {
+			return tok, nil
+		}
+	}
+	return getToken()
+}
+
+func (envTokenStore) Save(tok *oauth2.Token) error {
+	cache, err := cacheTokenStore()
 	if err != nil {
-		log.Fatalf("Unable to read client secret file: %v", err)
+		return err
 	}
-	t := &oauth2.Token{}
-	err = json.Unmarshal(f, t)
-	return t, err
+	return cache.Save(tok)
 }
 
-func main() {
-	// client_secret.jsonとoauth2.jsonのパスを設定
-	b, err := createClinetSecret()
+// Delete は、トークンのキャッシュファイルを削除します。環境変数のトークンは削除できません。
+func (envTokenStore) Delete() error {
+	cache, err := cacheTokenStore()
 	if err != nil {
-		fmt.Printf("Unable to read client secret file: %v", err)
+		return err
 	}
+	return cache.Delete()
+}
 
-	// OAuth2クライアント作成
-	ctx := context.Background()
-	config, err := google.ConfigFromJSON(b, youtube.YoutubeUploadScope)
+// getToken は、.envまたは環境変数に設定された資格情報をトークンに変換します。
+// 有効期限が設定されていない場合は、期限切れとして扱い最初の使用時に更新させます。
+func getToken() (*oauth2.Token, error) {
+	f, err := createOAuth2()
 	if err != nil {
-		fmt.Println("Error:", err)
-		return
+		return nil, err
+	}
+	var creds oAuth2Credentials
+	if err := json.Unmarshal(f, &creds); err != nil {
+		return nil, err
 	}
-	token, err := getToken()
+	tok, err := creds.token()
 	if err != nil {
-		fmt.Println("Error:", err)
-		return
+		return nil, err
+	}
+	if tok.Expiry.IsZero() && tok.RefreshToken != "" {
+		tok.Expiry = time.Now()
+	}
+	return tok, nil
+}
+
+// tokenStore は、newService がトークンを読み込む TokenStore です。
+// 既定では.envまたは環境変数から読み込み、-token-fd が指定された場合はそこから読み込んだトークンを使います。
+// シークレットマネージャーやRedisなどに保存する場合は、TokenStore を実装したものをここに設定します。
+var tokenStore TokenStore = envTokenStore{}
+
+// newService は、スコープを指定してOAuth2クライアントとYouTube APIサービスを作成します。
+// 設定を組み立てる前に validateEnv で環境変数を検証します。
+func newService(ctx context.Context, scopes ...string) (*http.Client, *youtube.Service, error) {
+	if err := validateAccount(account); err != nil {
+		return nil, nil, err
+	}
+	if err := validateEnv(); err != nil {
+		return nil, nil, err
 	}
-	client := config.Client(ctx, token)
+	ctx = withHTTPClient(ctx)
+	// OAuth2クライアント作成
+	ts, err := TokenSource(ctx, clientSecretFile, scopes, tokenStore)
+	if err != nil {
+		return nil, nil, err
+	}
+	client := oauth2.NewClient(ctx, ts)
 
 	// YouTube APIサービス作成
 	service, err := youtube.New(client)
 	if err != nil {
-		fmt.Println("Error:", err)
-		return
+		return nil, nil, err
 	}
+	return client, service, nil
+}
 
-	// 動画アップロード
-	upload := &youtube.Video{
-		Snippet: &youtube.VideoSnippet{
-			Title:       "testtitle",
-			Description: "testdescription",
-			CategoryId:  "22",
-		},
-		Status: &youtube.VideoStatus{PrivacyStatus: "unlisted"},
+// commands は、サブコマンド名と実行する関数の対応表です。
+// サブコマンドが指定されない場合は、動画をアップロードします。
+var commands = map[string]func(args []string) error{
+	"export-token":  runExportToken,
+	"import-token":  runImportToken,
+	"delete":        runDelete,
+	"preflight":     runPreflight,
+	"copy-metadata": runCopyMetadata,
+	"quota":         runQuota,
+	"channels":      runChannels,
+	"categories":    runCategories,
+	"revoke":        runRevoke,
+	"update":        runUpdate,
+	"get":           runGet,
+}
+
+// singleVideoFlags は、バッチモード以外でアップロードする1件の動画のメタデータを指定するフラグです。
+var singleVideoFlags = []string{"file", "title", "desc", "tags", "privacy", "category", "thumbnail", "publish-at", "captions"}
+
+// isSingleVideoFlag は、指定された名前が singleVideoFlags に含まれるかどうかを返します。
+func isSingleVideoFlag(name string) bool {
+	for _, f := range singleVideoFlags {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+func main() {
+	// ログに資格情報が残らないよう、すべてのログを伏せてから出力する
+	log.SetOutput(redactingWriter{w: os.Stderr})
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatalf("Unable to load %s: %v", configFileName, err)
+	}
+	cfg.applyEnv()
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
 	}
 
-	// APIは、tagsが空文字列の場合、400 Bad Requestレスポンスを返す。
-	if strings.Trim("golang test", "") != "" {
-		upload.Snippet.Tags = strings.Split("golang test", ",")
+	videoFile := flag.String("file", "gotest.mp4", `Video file to upload ("-" reads from stdin)`)
+	title := flag.String("title", "testtitle", "Title of the video; may be a Go text/template using -var variables")
+	description := flag.String("desc", "testdescription", "Description of the video; may be a Go text/template using -var variables")
+	tags := flag.String("tags", "golang test", "Comma-separated tags of the video")
+	privacy := flag.String("privacy", "unlisted", "Privacy of the video: "+strings.Join(privacyStatuses, ", ")+" (validated unless -no-validate)")
+	category := flag.String("category", "22", "Category ID or name (e.g. 22 or \"People & Blogs\") of the video")
+	categoryRegion := flag.String("category-region", defaultCategoryRegion, "Region whose category names -category and the category field are resolved against")
+	dryRun := flag.Bool("dry-run", false, "Print the video resources that would be sent as JSON and exit without uploading (-watch-next is not applied)")
+	var madeForKids optionalBool
+	flag.Var(&madeForKids, "made-for-kids", "Declare whether the video is made for kids: true or false (required unless set in the metadata file)")
+	publishAtFlag := flag.String("publish-at", "", "RFC3339 time to publish the video automatically; the video is uploaded as private until then")
+	thumbnail := flag.String("thumbnail", "", "JPEG or PNG image to set as the thumbnail after the upload (requires a verified channel)")
+	concurrency := flag.Int("concurrency", defaultConcurrency, fmt.Sprintf("Number of videos to upload at once in batch mode (at most %d)", maxConcurrency))
+	batchFile := flag.String("batch", "", "CSV file, or JSON array of -metadata objects (.json), listing videos to upload")
+	columnMap := flag.String("column-map", "", "Mapping of metadata fields to CSV headers (e.g. file=File,title=Title)")
+	playlistID := flag.String("playlist", "", "ID of a playlist to add the uploaded video to")
+	playlistPosition := flag.String("playlist-position", "end", "Zero-based position in -playlist to insert the video at, or end")
+	onConflict := flag.String("on-conflict", onConflictSkip, "What to do when the video is already in the playlist: skip, add or error")
+	listenAddr := flag.String("listen", "", "Address to serve /healthz, /readyz, /status and /metrics on in batch mode (e.g. :8080)")
+	maxFailuresFlag := flag.Int("max-failures", 0, "In batch mode, stop after this many uploads fail (0 to run every item); a batch with any failed upload exits with code 1")
+	failFast := flag.Bool("fail-fast", false, "In batch mode, stop at the first failed upload (same as -max-failures 1)")
+	siblingThumbnail := flag.Bool("auto-thumbnail-sibling", false, "In batch mode, set a JPEG or PNG next to each video with the same name (video.mp4 -> video.jpg) as its thumbnail")
+	autoThumbnail := flag.Int("select-auto-thumbnail", 0, "Index (1-3) of the auto-generated thumbnail to use (not supported by the API)")
+	clipStart := flag.String("clip-start", "", "Upload only the part of the file from this timestamp (requires ffmpeg)")
+	clipEnd := flag.String("clip-end", "", "Upload only the part of the file up to this timestamp (requires ffmpeg)")
+	tagsVocab := flag.String("tags-vocab", "", "File listing the approved tags, one per line")
+	enforceVocab := flag.Bool("enforce-vocab", false, "Reject tags that are not in -tags-vocab")
+	var templateVars stringList
+	flag.Var(&templateVars, "var", "Variable for the -title and -desc templates as key=value (e.g. N=12 for \"Episode {{.N}}\"); repeat for more")
+	var captionFiles stringList
+	flag.Var(&captionFiles, "captions", "Caption file to add after upload as language:file (e.g. en:video.srt); repeat for more languages")
+	var metadataFiles stringList
+	flag.Var(&metadataFiles, "metadata", "JSON file with metadata defaults; repeat to layer files, later ones override earlier ones")
+	tagsMerge := flag.String("tags-merge", tagMergeReplace, "How tags from later -metadata files and batch rows combine: replace, append or union")
+	truncate := flag.Bool("truncate-tags", false, "Drop the last tags until the tags fit in 500 characters instead of failing")
+	autoTags := flag.Bool("auto-tags", false, "Add tags derived from significant words in the title and description")
+	noResume := flag.Bool("no-resume", false, "Start over instead of resuming a previously interrupted upload")
+	successHTML := flag.String("success-html", "", "HTML template shown in the browser after OAuth consent (default: plain text)")
+	partsFlag := flag.String("parts", "", "Comma-separated resource parts to send (default: inferred from the metadata)")
+	minimal := flag.Bool("minimal-parts", false, "Send only snippet and status; recording_date and localizations from the metadata are not applied")
+	teePath := flag.String("tee", "", "Also write the uploaded bytes to this local file")
+	noValidate := flag.Bool("no-validate", false, "Skip local validation of privacy, category and the video file and let the API decide")
+	watchNext := flag.String("watch-next", "", "Comma-separated video IDs to link at the end of the description")
+	contentOwner := flag.String("onbehalf-content-owner", "", "Content owner ID to upload on behalf of; the account must be linked to that YouTube CMS content owner")
+	contentOwnerChannel := flag.String("onbehalf-channel-id", "", "Channel managed by -onbehalf-content-owner to upload to (e.g. a brand channel); requires a CMS-linked account")
+	autoFixTags := flag.Bool("auto-fix-tags", false, "When YouTube rejects the tags, clean them and retry once")
+	syncDir := flag.String("sync-dir", "", "Directory whose video files changed since the last successful sync are uploaded")
+	fullScan := flag.Bool("full-scan", false, "With -sync-dir, upload every video file regardless of the last sync")
+	sessionTimeout := flag.Duration("session-timeout", defaultSessionTimeout, "Timeout for the request that creates the upload session and sends the metadata (0 for none)")
+	chunkTimeout := flag.Duration("chunk-timeout", defaultChunkTimeout, "Timeout for each request that sends a chunk of the video (0 for none)")
+	addAccountFlag(flag.CommandLine)
+	scopesFlag := flag.String("scopes", "upload", "Comma-separated OAuth scopes to request: upload, readonly, youtube, force-ssl, partner or full URLs")
+	maxAttempts := flag.Int("max-attempts", defaultMaxAttempts, "Attempts for API requests failing with 429, 500, 502 or 503, including the first")
+	noProgress := flag.Bool("no-progress", false, "Do not print upload progress (a bar on terminals, a line per chunk otherwise)")
+	stallTimeout := flag.Duration("stall-timeout", defaultStallTimeout, "Cancel and retry a chunk when no bytes are sent and no response arrives for this long (0 to disable)")
+	timeout := flag.Duration("timeout", 0, "Abort the whole run, including authorization and all uploads, after this long (0 for none)")
+	waitProcessing := flag.Bool("wait-processing", false, "After upload, wait until YouTube finishes processing the video and fail if processing fails")
+	claimsWindow := flag.Duration("wait-for-claims", 0, "After upload, watch the video this long for copyright claim indicators (e.g. 10m)")
+	strict := flag.Bool("strict", false, "Fail when the uploaded video's privacy differs from the requested one instead of warning")
+	contentLength := flag.Int64("content-length", 0, "Total bytes of a non-seekable video stream (e.g. stdin), advertised to the upload session")
+	readBuffer := flag.Int("read-buffer", defaultReadBuffer, "Bytes to read from the video file at a time; raise it on high-latency filesystems")
+	flag.BoolVar(&forceIPv4, "force-ipv4", false, "Connect to YouTube and the token endpoint over IPv4 only")
+	caCert := flag.String("ca-cert", "", "PEM file with a CA certificate to trust in addition to the system roots (e.g. a TLS-inspecting proxy)")
+	insecureSkipVerify := flag.Bool("insecure-skip-verify", false, "Do not verify TLS certificates (last resort, insecure)")
+	tokenFD := flag.Int("token-fd", -1, "Read the token JSON from this open file descriptor instead of the environment")
+	flag.BoolVar(&compactJSON, "compact", false, "Write the token cache as compact single-line JSON")
+	flag.BoolVar(&noRedact, "no-redact", false, "Print tokens, secrets and authorization codes in logs as is (local debugging only)")
+	flag.BoolVar(&authQR, "auth-qr", false, "Also show the authorization URL as a QR code when prompting for the code")
+	flag.StringVar(&clientSecretFile, "client-secret", "", "Client secret JSON file to authorize with (default $"+clientSecretFileEnv+", or the client ID and secret in the environment)")
+	flag.StringVar(&authMode, "auth", authMode, "Authorization flow when no token is cached: web, prompt or device (device needs a TV and Limited Input client and -scopes youtube)")
+	flag.DurationVar(&authTimeout, "auth-timeout", authTimeout, "How long the web authorization flow waits for the browser before giving up (0 to wait forever)")
+	out := newCommandOutput(flag.CommandLine, "upload")
+	videoType := flag.String("video-type", videoTypeVideo, "Kind of upload: video, short (adds #Shorts) or unlisted")
+	if err := cfg.applyFlagDefaults(flag.CommandLine); err != nil {
+		log.Fatal(err)
 	}
+	flag.Parse()
+	out.start()
 
-	call := service.Videos.Insert([]string{"snippet", "status"}, upload)
+	if *tokenFD >= 0 {
+		tok, err := readTokenFD(*tokenFD)
+		if err != nil {
+			out.fatal(err)
+		}
+		tokenStore = staticTokenStore{tok: tok}
+	}
+	if err := configureTLS(*caCert, *insecureSkipVerify); err != nil {
+		out.fatal(err)
+	}
+	if err := validateOnConflict(*onConflict); err != nil {
+		out.fatal(err)
+	}
+	if err := selectAutoThumbnail(*autoThumbnail); err != nil {
+		out.fatal(err)
+	}
+	if *batchFile != "" && *syncDir != "" {
+		out.fatal("-batch and -sync-dir cannot be used together")
+	}
+	if *fullScan && *syncDir == "" {
+		out.fatal("-full-scan requires -sync-dir")
+	}
+	batchMode := *batchFile != "" || *syncDir != ""
+	maxFailures := *maxFailuresFlag
+	if *failFast {
+		if maxFailures > 0 {
+			out.fatal("-fail-fast and -max-failures cannot be used together")
+		}
+		maxFailures = 1
+	}
+	if maxFailures < 0 {
+		out.fatalf("invalid -max-failures %d, must not be negative", maxFailures)
+	}
+	if maxFailures > 0 && !batchMode {
+		out.fatal("-max-failures and -fail-fast are only supported in batch mode")
+	}
+	if batchMode {
+		flag.Visit(func(f *flag.Flag) {
+			if isSingleVideoFlag(f.Name) {
+				out.fatalf("-%s is not supported in batch mode, set it per item or with -metadata", f.Name)
+			}
+		})
+	}
+	if err := validateConcurrency(*concurrency); err != nil {
+		out.fatal(err)
+	}
+	if *concurrency != defaultConcurrency && !batchMode {
+		out.fatal("-concurrency is only supported in batch mode")
+	}
+	if *siblingThumbnail && !batchMode {
+		out.fatal("-auto-thumbnail-sibling is only supported in batch mode")
+	}
+	if *listenAddr != "" && !batchMode {
+		out.fatal("-listen is only supported in batch mode")
+	}
+	clipping := *clipStart != "" || *clipEnd != ""
+	if clipping && batchMode {
+		out.fatal("-clip-start and -clip-end are not supported in batch mode")
+	}
+	if *teePath != "" && batchMode {
+		out.fatal("-tee is not supported in batch mode")
+	}
+	if *successHTML != "" {
+		if err := loadSuccessPage(*successHTML); err != nil {
+			out.fatalf("Unable to load -success-html template: %v", err)
+		}
+	}
+	if (*contentOwner == "") != (*contentOwnerChannel == "") {
+		out.fatal("-onbehalf-content-owner and -onbehalf-channel-id must be used together")
+	}
+	if *enforceVocab && *tagsVocab == "" {
+		out.fatal("-enforce-vocab requires -tags-vocab")
+	}
+	position, err := parsePlaylistPosition(*playlistPosition)
+	if err != nil {
+		out.fatal(err)
+	}
+	if err := validateAccount(account); err != nil {
+		out.fatal(err)
+	}
+	if err := validateAuthMode(authMode); err != nil {
+		out.fatal(err)
+	}
+	if authTimeout < 0 {
+		out.fatalf("invalid -auth-timeout %v, must not be negative", authTimeout)
+	}
+	publishAt, err := parsePublishAt(*publishAtFlag, time.Now())
+	if err != nil {
+		out.fatal(err)
+	}
+	vars, err := parseTemplateVars(templateVars)
+	if err != nil {
+		out.fatal(err)
+	}
+	captions, err := parseCaptions(captionFiles)
+	if err != nil {
+		out.fatal(err)
+	}
+	scopes, err := parseScopes(*scopesFlag)
+	if err != nil {
+		out.fatalf("Invalid -scopes: %v", err)
+	}
+	if *timeout < 0 {
+		out.fatalf("invalid -timeout %v, must not be negative", *timeout)
+	}
+	if *maxAttempts < 1 {
+		out.fatalf("invalid -max-attempts %d, must be at least 1", *maxAttempts)
+	}
+	if *readBuffer <= 0 {
+		out.fatalf("invalid -read-buffer %d, must be positive", *readBuffer)
+	}
+	if err := validateTagMerge(*tagsMerge); err != nil {
+		out.fatal(err)
+	}
+	parts, err := parseParts(*partsFlag)
+	if err != nil {
+		out.fatalf("Invalid -parts: %v", err)
+	}
+	if *minimal {
+		if len(parts) > 0 {
+			out.fatal("-minimal-parts and -parts cannot be used together")
+		}
+		parts = minimalParts
+	}
+	// バーは1行を描き直すため、ログに残す場合や複数を同時に表示する場合は行ごとの表示にする
+	progressBar := isTerminal(os.Stdout) && isTerminal(os.Stderr) && !out.jsonMode() && *concurrency == 1
+	opts := uploadOptions{
+		PlaylistID:          *playlistID,
+		OnConflict:          *onConflict,
+		PlaylistPosition:    position,
+		Parts:               parts,
+		MinimalParts:        *minimal,
+		NoResume:            *noResume,
+		Tee:                 *teePath,
+		ContentOwner:        *contentOwner,
+		ContentOwnerChannel: *contentOwnerChannel,
+		SessionTimeout:      *sessionTimeout,
+		ChunkTimeout:        *chunkTimeout,
+		StallTimeout:        *stallTimeout,
+		NoProgress:          *noProgress,
+		ProgressBar:         progressBar,
+		MaxAttempts:         *maxAttempts,
+		AutoFixTags:         *autoFixTags,
+		Strict:              *strict,
+		WaitForProcessing:   *waitProcessing,
+		ClaimsWindow:        *claimsWindow,
+		ReadBuffer:          *readBuffer,
+		ContentLength:       *contentLength,
+		Metrics:             newUploadMetrics(),
+	}
 
-	file, err := os.Open("gotest.mp4")
-	defer file.Close()
+	// -metadata のファイルを重ねた共通のメタデータ。バッチモードでは各項目の既定値になる
+	defaults, err := loadMetadataLayers(metadataFiles, *tagsMerge)
 	if err != nil {
-		log.Fatalf("Error opening %v: %v", "gotest.mp4", err)
+		out.fatalf("Unable to read -metadata: %v", err)
+	}
+
+	// アップロードする動画の一覧。バッチモード以外では1件のみ。
+	// 認証より先にメタデータを検証する
+	var items []videoMetadata
+	var lastSync syncState
+	if *syncDir != "" {
+		lastSync, err = loadSyncState(*syncDir)
+		if err != nil {
+			out.fatalf("Unable to read sync state: %v", err)
+		}
+		since := lastSync.LastModTime
+		if *fullScan {
+			since = time.Time{}
+		}
+		items, lastSync.LastModTime, err = scanSyncDir(*syncDir, since)
+		if err != nil {
+			out.fatalf("Unable to scan -sync-dir: %v", err)
+		}
+		if len(items) == 0 {
+			fmt.Printf("No video files in %s changed since %v\n", *syncDir, since)
+			out.finish(newBatchStatus(0).report(), nil)
+			return
+		}
+	} else if *batchFile != "" {
+		items, err = readBatchFile(*batchFile, *columnMap)
+		if err != nil {
+			out.fatalf("Unable to read batch file: %v", err)
+		}
+	} else {
+		items = []videoMetadata{{
+			File:        *videoFile,
+			Title:       *title,
+			Description: *description,
+			Tags:        splitTags(*tags),
+			Privacy:     *privacy,
+			CategoryID:  *category,
+			Thumbnail:   *thumbnail,
+			PublishAt:   publishAt,
+			Captions:    captions,
+		}}
+		// 動画をアップロードしてから画像の誤りに気付かないよう、先に検証する
+		if *thumbnail != "" {
+			info, err := os.Stat(*thumbnail)
+			if err != nil {
+				out.fatalf("Invalid -thumbnail: %v", err)
+			}
+			if err := validateThumbnail(*thumbnail, info.Size()); err != nil {
+				out.fatalf("Invalid -thumbnail %s: %v", *thumbnail, err)
+			}
+		}
+	}
+	for i := range items {
+		if batchMode {
+			// 各項目の値は -metadata の既定値より優先する
+			items[i] = overlayMetadata(overlayMetadata(batchDefaults, defaults, *tagsMerge), items[i], *tagsMerge)
+		} else {
+			items[i] = overlayMetadata(items[i], defaults, *tagsMerge)
+		}
+		// 自動タグやShortsのタグが展開後のタイトルを使えるよう、先に展開する
+		if err := applyTemplates(&items[i], vars); err != nil {
+			out.fatalf("%v: %v", items[i].File, err)
+		}
+	}
+	if *siblingThumbnail {
+		for i := range items {
+			thumbnail, err := findSiblingThumbnail(items[i].File)
+			if err != nil {
+				log.Printf("%v: not setting a thumbnail: %v", items[i].File, err)
+				continue
+			}
+			items[i].Thumbnail = thumbnail
+		}
+	}
+	var vocab map[string]string
+	if *tagsVocab != "" {
+		vocab, err = loadTagVocabulary(*tagsVocab)
+		if err != nil {
+			out.fatalf("Unable to read tag vocabulary: %v", err)
+		}
+	}
+	if *autoTags {
+		// -enforce-vocab では、語彙にない候補を加えるとエラーになるため語彙に含まれる候補だけを使う
+		var allowed map[string]string
+		if *enforceVocab {
+			allowed = vocab
+		}
+		for i := range items {
+			items[i].Tags = withAutoTags(items[i].Tags, items[i].Title, items[i].Description, allowed)
+		}
+	}
+	if vocab != nil {
+		for i := range items {
+			items[i].Tags, err = applyTagVocabulary(items[i].Tags, vocab, *enforceVocab)
+			if err != nil {
+				out.fatalf("%v: %v", items[i].File, err)
+			}
+		}
+	}
+	if *truncate {
+		for i := range items {
+			items[i].Tags = truncateTags(items[i].Tags)
+		}
+	}
+	for i := range items {
+		if err := applyVideoType(&items[i], *videoType); err != nil {
+			out.fatal(err)
+		}
+		items[i].CategoryID, err = resolveCategory(items[i].CategoryID, *categoryRegion)
+		if err != nil {
+			out.fatalf("%v: %v", items[i].File, err)
+		}
+		applyPublishAt(&items[i])
+		if err := applyMadeForKids(&items[i], madeForKids.value); err != nil {
+			out.fatalf("%v: %v", items[i].File, err)
+		}
+	}
+	if *noValidate {
+		log.Println("Skipping local validation of privacy, category and video files (-no-validate)")
+	}
+	var videos []*youtube.Video
+	for _, item := range items {
+		if !*noValidate {
+			if err := validateMetadata(item); err != nil {
+				out.fatalf("%v: %v", item.File, err)
+			}
+			// 帯域を無駄にしないよう、形式や大きさの誤りは送信の前に検出する
+			if err := validateVideoFile(item.File); err != nil {
+				out.fatal(err)
+			}
+		}
+		video := buildVideo(item)
+		if opts.MinimalParts {
+			stripOptionalParts(video)
+		}
+		if _, err := resolveParts(video, opts.Parts); err != nil {
+			out.fatalf("%v: %v", item.File, err)
+		}
+		videos = append(videos, video)
+	}
+	if *dryRun {
+		if err := printDryRun(items, videos); err != nil {
+			out.fatal(err)
+		}
+		out.finish(videos, nil)
+		return
 	}
 
-	response, err := call.Media(file).Do()
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	if opts.PlaylistID != "" && !containsScope(scopes, youtube.YoutubeScope) {
+		// playlistItems.insertはアップロードのスコープでは許可されない
+		scopes = append(scopes, youtube.YoutubeScope)
+	}
+	if len(captions) > 0 && !containsScope(scopes, youtube.YoutubeForceSslScope) {
+		// captions.insertはforce-sslのスコープが必要
+		scopes = append(scopes, youtube.YoutubeForceSslScope)
+	}
+	if opts.ContentOwner != "" && !containsScope(scopes, youtube.YoutubepartnerScope) {
+		// onBehalfOfContentOwnerとmanagedByMeのchannels.listはパートナーのスコープが必要
+		scopes = append(scopes, youtube.YoutubepartnerScope)
+	}
+	if (*watchNext != "" || opts.WaitForProcessing || opts.ClaimsWindow > 0) && !canRead(scopes) {
+		// -watch-next の動画のタイトル、-wait-processing の処理状況と -wait-for-claims の申し立ての兆候はvideos.listで読み取る
+		scopes = append(scopes, youtube.YoutubeReadonlyScope)
+	}
+	// 認証を待つ間も監視から見えるよう、ヘルスチェックのサーバーは認証の前に起動する
+	var status *batchStatus
+	if batchMode {
+		status = newBatchStatus(len(items))
+		if *listenAddr != "" {
+			server, err := startHealthServer(*listenAddr, status, opts.Metrics)
+			if err != nil {
+				out.fatalf("Unable to start health server: %v", err)
+			}
+			defer server.Close()
+		}
+	}
+	client, service, err := newService(ctx, scopes...)
+	if errors.Is(err, context.DeadlineExceeded) {
+		out.fatalf("Authorization did not finish within -timeout %v", *timeout)
+	}
 	if err != nil {
-		log.Fatalf("Error making YouTube API call: %v", err)
+		out.fatalf("Error: %v", redactErr(err))
 	}
+	u := newUploader(client, service, opts)
+	// 認証の入力中はCtrl+Cですぐに終了できるよう、認証の後で設定する
+	handleShutdownSignals()
+
+	if opts.ContentOwner != "" {
+		if err := validateManagedChannel(service, opts.ContentOwner, opts.ContentOwnerChannel); err != nil {
+			out.fatal(err)
+		}
+	}
+
+	if ids := parseVideoIDs(*watchNext); len(ids) > 0 {
+		block, err := watchNextBlock(service, ids)
+		if err != nil {
+			out.fatal(err)
+		}
+		for i := range items {
+			items[i].Description, err = appendWatchNext(items[i].Description, block)
+			if err != nil {
+				out.fatalf("%v: %v", items[i].File, err)
+			}
+		}
+	}
+
+	if batchMode {
+		err := runBatch(ctx, u, items, status, maxFailures, *concurrency)
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil {
+			out.fatalf("Batch did not finish within -timeout %v: %v", *timeout, err)
+		}
+		if errors.Is(err, errInterrupted) {
+			out.exit(exitInterrupted, err)
+		}
+		// アップロードが一部でも失敗した場合は、次回も同じファイルを対象にするため状態を更新しない。
+		// 後続の処理だけが失敗した場合は、動画が重複しないよう状態を更新する
+		code := batchExitCode(err)
+		if code != 0 && code != exitPartialSuccess {
+			out.exit(code, err)
+		}
+		if *syncDir != "" {
+			if err := saveSyncState(lastSync); err != nil {
+				out.fatalf("Unable to save sync state: %v", err)
+			}
+		}
+		if code == exitPartialSuccess {
+			out.exit(code, err)
+		}
+		out.finish(status.report(), nil)
+		return
+	}
+
+	// 動画アップロード
+	meta := items[0]
+	// 切り出した一時ファイルは、アップロードの成否に関わらず削除する
+	cleanup := func() {}
+	if clipping {
+		clipFile, removeClip, err := clipVideo(meta.File, *clipStart, *clipEnd)
+		if err != nil {
+			out.fatalf("Unable to clip %v: %v", meta.File, err)
+		}
+		meta.File, cleanup = clipFile, removeClip
+	}
+	response, err := u.upload(ctx, meta)
+	cleanup()
+	// responseがあれば動画はアップロード済みで、errはその後の確認の失敗のため、後続の処理と同じく扱う
+	if response == nil {
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil {
+			out.fatalf("Upload did not finish within -timeout %v: %v", *timeout, err)
+		}
+		if errors.Is(err, errInterrupted) {
+			out.exit(exitInterrupted, err)
+		}
+		out.fatal(err)
+	}
+	// 後続の処理が失敗しても動画IDが埋もれないよう、先に表示しておく
 	fmt.Printf("Upload successful! Video ID: %v\n", response.Id)
+	if !meta.PublishAt.IsZero() {
+		fmt.Printf("Scheduled to be published at %s\n", meta.PublishAt.Format(time.RFC3339))
+	}
+
+	if err := errors.Join(err, u.postUpload(ctx, response, meta)); err != nil {
+		out.exit(exitPartialSuccess, err)
+	}
+	result := uploadResult{ID: response.Id, URL: "https://youtu.be/" + response.Id, Status: "uploaded"}
+	if !meta.PublishAt.IsZero() {
+		result.Status = "scheduled"
+		result.PublishAt = meta.PublishAt.Format(time.RFC3339)
+	}
+	out.finish(result, nil)
 }
 
+// uploadResult は、アップロードの -json と -output=json で出力する結果です。
+type uploadResult struct {
+	ID     string `json:"id"`
+	URL    string `json:"url"`
+	Status string `json:"status"`
+	// PublishAt は、公開を予約した場合の公開時刻です。
+	PublishAt string `json:"publish_at,omitempty"`
+}
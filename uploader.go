@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+
+	"github.com/maguro-alternative/youtube-go/upload"
+)
+
+// videoMetadata は、アップロードする動画1件分のメタデータです。
+// ライブラリとして使えるよう、定義は upload パッケージにあります。
+type videoMetadata = upload.VideoMetadata
+
+// videoLocalization は、1つの言語のタイトルと説明です。
+type videoLocalization = upload.Localization
+
+// buildVideo は、メタデータからVideos.Insertに渡すyoutube.Videoを組み立てます。
+func buildVideo(meta videoMetadata) *youtube.Video {
+	return upload.BuildVideo(meta)
+}
+
+// uploader は、認証済みのクライアントと共通の設定を持ち、動画のアップロードと後続の処理を行います。
+// バッチモードでは1つの uploader で全項目をアップロードします。
+type uploader struct {
+	client  *http.Client
+	service *youtube.Service
+	opts    uploadOptions
+}
+
+// newUploader は、newService が返したクライアントとサービスで uploader を作成します。
+func newUploader(client *http.Client, service *youtube.Service, opts uploadOptions) *uploader {
+	return &uploader{client: client, service: service, opts: opts}
+}
+
+// upload は、メタデータに指定されたファイルを動画としてアップロードします。
+// 再開可能アップロードのセッション作成時にメタデータ全体を送信するため、
+// メタデータの誤りは動画のバイト列を送信する前に検出されます。
+// 通常のファイルは中断したセッションを保存して再開できるようにし、パイプなどのストリームは uploadReader に任せます。
+// アップロードされた動画のリソースを返します。
+// 動画がアップロードされた後の確認で失敗した場合は、動画のリソースとエラーの両方を返します。
+// ctxが取り消された場合は、送信中のリクエストを中止してエラーを返します。
+func (u *uploader) upload(ctx context.Context, meta videoMetadata) (*youtube.Video, error) {
+	file, err := openVideoFile(meta.File)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening %v: %v", meta.File, err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("Error opening %v: %v", meta.File, err)
+	}
+	// パイプなどのストリームはシークできないため、中断しても再開できない。
+	// サイズは -content-length で宣言された場合だけセッションに伝える
+	if !info.Mode().IsRegular() {
+		size := int64(-1)
+		if u.opts.ContentLength > 0 {
+			size = u.opts.ContentLength
+		}
+		return u.uploadReader(ctx, meta, file, size)
+	}
+	if u.opts.ContentLength > 0 && info.Size() != u.opts.ContentLength {
+		return nil, fmt.Errorf("%v has %d bytes but -content-length is %d", meta.File, info.Size(), u.opts.ContentLength)
+	}
+
+	start := time.Now()
+	session, offset, completed := resumeSavedSession(ctx, u.client, meta.File, info, u.opts.NoResume, u.opts.ChunkTimeout)
+	if completed != nil {
+		// 以前の実行でバイト列を送り終えているため、この実行で送ったバイト数は0として記録する
+		return completed, u.uploaded(meta, completed, 0, time.Since(start))
+	}
+	if session == nil {
+		session, err = u.startSession(ctx, meta, info.Size())
+		if err != nil {
+			return nil, err
+		}
+		// 中断した場合に再開できるよう、セッションを保存する
+		saved := savedSession{
+			URI:     session.URI,
+			File:    meta.File,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			Created: time.Now(),
+		}
+		if err := saveSession(meta.File, saved); err != nil {
+			fmt.Printf("Unable to save upload session, it cannot be resumed: %v\n", redactErr(err))
+		}
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return u.send(ctx, meta, session, file, offset, io.NewSectionReader(file, 0, offset), true)
+}
+
+// uploadReader は、rから読み込んだバイト列を動画としてアップロードします。
+// ffmpegの出力やリモートのオブジェクトなど、ディスク上のファイルがない場合に使います。
+// sizeは全体のバイト数で、不明な場合は-1を指定します。指定した場合は、rの長さが一致しなければエラーになります。
+// meta.File はContent-Typeの推測と表示だけに使い、開きません。
+// 読み直せないため、中断したアップロードは再開できません。
+func (u *uploader) uploadReader(ctx context.Context, meta videoMetadata, r io.Reader, size int64) (*youtube.Video, error) {
+	if size > 0 {
+		r = newExactLengthReader(r, size)
+	}
+	session, err := u.startSession(ctx, meta, size)
+	if err != nil {
+		return nil, err
+	}
+	return u.send(ctx, meta, session, r, 0, nil, false)
+}
+
+// startSession は、メタデータから動画のリソースを組み立て、再開可能アップロードのセッションを作成します。
+// APIがタグを拒否した場合は、-auto-fix-tags が指定されていればタグを修正して1回だけ再試行します。
+func (u *uploader) startSession(ctx context.Context, meta videoMetadata, size int64) (*resumableSession, error) {
+	video := buildVideo(meta)
+	if u.opts.MinimalParts {
+		if dropped := stripOptionalParts(video); len(dropped) > 0 {
+			log.Printf("%s: -minimal-parts: not sending %s", meta.File, strings.Join(dropped, ", "))
+		}
+	}
+	parts, err := resolveParts(video, u.opts.Parts)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", meta.File, err)
+	}
+	query := url.Values{}
+	if u.opts.ContentOwner != "" {
+		query.Set("onBehalfOfContentOwner", u.opts.ContentOwner)
+		query.Set("onBehalfOfContentOwnerChannel", u.opts.ContentOwnerChannel)
+	}
+	// セッションの作成は動画のバイト列を送る前のため、何度試みても動画は重複しない
+	var session *resumableSession
+	start := func() error {
+		return doWithRetry(ctx, "Creating the upload session", u.opts.MaxAttempts, u.opts.Metrics, func() error {
+			var err error
+			session, err = startResumableSession(ctx, u.client, u.service, video,
+				parts, videoContentType(meta.File), size, query, u.opts.SessionTimeout)
+			return err
+		})
+	}
+	err = start()
+	if isTagsError(err) {
+		u.opts.Metrics.observeError(err)
+		if !u.opts.AutoFixTags {
+			return nil, fmt.Errorf("Error starting upload of %v: %w", meta.File, describeTagsError(meta.Tags))
+		}
+		// タグを修正して1回だけ再試行する
+		video.Snippet.Tags = fixTags(meta.Tags)
+		err = start()
+	}
+	if err != nil {
+		u.opts.Metrics.observeError(err)
+		return nil, fmt.Errorf("Error starting upload of %v: %w", meta.File, withQuotaHint("videos.insert", err))
+	}
+	session.chunkTimeout = u.opts.ChunkTimeout
+	return session, nil
+}
+
+// send は、offsetの位置から読み込めるrのバイト列をセッションで送信し、完了した動画のリソースを返します。
+// sentは、-tee のファイルに先に書き出す送信済みの部分です。offsetが0の場合はnilで構いません。
+// saved が true の場合は、完了した後で保存されたセッションを削除します。
+func (u *uploader) send(ctx context.Context, meta videoMetadata, session *resumableSession,
+	r io.Reader, offset int64, sent io.Reader, saved bool) (*youtube.Video, error) {
+	start := time.Now()
+	session.stallTimeout = u.opts.StallTimeout
+	switch {
+	case u.opts.NoProgress:
+	case u.opts.ProgressBar:
+		bar := newProgressBar(os.Stderr, meta.File)
+		session.transfer = bar.update
+		defer bar.done()
+	default:
+		session.progress = printProgress(meta.File)
+	}
+	counter := &countingReader{r: readSizeReader{r: r, size: u.opts.ReadBuffer}}
+	r = counter
+	// -tee が指定された場合は、送信したバイト列をローカルにも書き出す。
+	// 再開したアップロードでは、送信済みの部分を先に書き出しておく
+	var tee *os.File
+	if u.opts.Tee != "" {
+		var err error
+		tee, err = os.Create(u.opts.Tee)
+		if err != nil {
+			return nil, fmt.Errorf("Error creating -tee file: %v", err)
+		}
+		defer tee.Close()
+		if sent != nil {
+			if _, err := io.Copy(tee, sent); err != nil {
+				return nil, fmt.Errorf("Error writing -tee file: %v", err)
+			}
+		}
+		r = io.TeeReader(r, tee)
+	}
+	response, err := session.upload(ctx, r, offset)
+	if err != nil {
+		u.opts.Metrics.observeError(err)
+		return nil, fmt.Errorf("Error making YouTube API call: %w", err)
+	}
+	if saved {
+		removeSavedSession(meta.File)
+	}
+	// 動画はアップロード済みのため、この後の確認で失敗しても動画IDが失われないよう動画のリソースも返す
+	err = u.uploaded(meta, response, counter.n, time.Since(start))
+	if tee != nil && err == nil {
+		teeInfo, statErr := tee.Stat()
+		switch {
+		case statErr != nil:
+			err = fmt.Errorf("Error checking -tee file: %v", statErr)
+		case teeInfo.Size() != offset+counter.n:
+			err = fmt.Errorf("-tee file %s has %d bytes but %d bytes were uploaded",
+				u.opts.Tee, teeInfo.Size(), offset+counter.n)
+		}
+	}
+	return response, err
+}
+
+// uploaded は、アップロードが完了した動画を結果ログとメトリクスに記録し、公開設定を確認します。
+// 中断したセッションがすでに完了していた場合も、同じ処理を通します。
+// -strict で公開設定が要求と異なる場合はエラーを返します。動画はアップロード済みのため、
+// 呼び出し元は動画のリソースと一緒に返し、動画IDが失われないようにします。
+func (u *uploader) uploaded(meta videoMetadata, response *youtube.Video, bytes int64, d time.Duration) error {
+	recordResult("videos.insert", response.Id)
+	u.opts.Metrics.observeUpload(bytes, d)
+	if err := verifyPrivacy(meta.Privacy, response); err != nil {
+		if u.opts.Strict {
+			return err
+		}
+		fmt.Printf("Warning: %v\n", err)
+	}
+	return nil
+}
+
+// uploadOptions は、メタデータ以外でアップロードの動作を変える設定です。
+type uploadOptions struct {
+	PlaylistID string
+	OnConflict string
+	// PlaylistPosition は、再生リスト内で動画を追加する0から始まる位置です。
+	// playlistPositionEnd の場合は末尾に追加します。
+	PlaylistPosition int64
+	// Parts は、Videos.Insertに渡す部分の一覧です。空の場合はメタデータから推測します。
+	Parts []string
+	// MinimalParts は、snippetとstatusだけを送り、それ以外の部分はデータがあっても送らないかどうかです。
+	MinimalParts bool
+	// NoResume は、保存された中断中のセッションを再開せず、最初からアップロードするかどうかです。
+	NoResume bool
+	// Tee は、アップロードしたバイト列のコピーを書き出すファイルのパスです。
+	Tee string
+	// ContentOwner は、コンテンツ所有者に代わってアップロードする場合のコンテンツ所有者IDです。
+	ContentOwner string
+	// ContentOwnerChannel は、ContentOwner が管理するアップロード先のチャンネルIDです。
+	ContentOwnerChannel string
+	// SessionTimeout は、再開可能アップロードのセッション作成のリクエストにかける時間の上限です。
+	SessionTimeout time.Duration
+	// ChunkTimeout は、チャンク送信のリクエスト1回にかける時間の上限です。
+	ChunkTimeout time.Duration
+	// StallTimeout は、チャンクの送信が進まないまま待つ時間の上限です。超えた場合は同じセッションで送り直します。
+	StallTimeout time.Duration
+	// MaxAttempts は、一時的なエラーで失敗したセッションの作成を試みる回数です。
+	MaxAttempts int
+	// NoProgress は、チャンクごとの進み具合の表示を止めるかどうかです。
+	NoProgress bool
+	// ProgressBar は、進み具合をチャンクごとの行ではなく、標準エラー出力に描き直すバーで表示するかどうかです。
+	ProgressBar bool
+	// ContentLength は、シークできないストリームの全体のバイト数です。0の場合は不明として扱います。
+	ContentLength int64
+	// ReadBuffer は、動画ファイルから1回に読み込むバイト数です。
+	ReadBuffer int
+	// WaitForProcessing は、アップロード後にサーバー側の処理が終わるまで待ち、失敗を報告するかどうかです。
+	WaitForProcessing bool
+	// ClaimsWindow は、アップロード後に著作権の申し立ての兆候を確認し続ける時間です。0の場合は確認しません。
+	ClaimsWindow time.Duration
+	// Strict は、アップロードした動画の公開設定が要求と異なる場合に、警告ではなくエラーにするかどうかです。
+	Strict bool
+	// AutoFixTags は、APIがタグを拒否したときにタグを修正して1回だけ再試行するかどうかです。
+	AutoFixTags bool
+	// Metrics は、アップロードの統計を記録するレジストリです。nilの場合は記録しません。
+	Metrics *uploadMetrics
+}
+
+// postUpload は、アップロードが成功した動画に対して後続の処理を行います。
+// 各処理は一時的なエラーであれば再試行し、1つが失敗しても残りの処理は続けます。
+// 失敗した処理がある場合は *subOperationError を返します。
+// ctxが取り消された場合は、失敗した処理を再試行しません。
+func (u *uploader) postUpload(ctx context.Context, video *youtube.Video, meta videoMetadata) error {
+	failed := &subOperationError{videoID: video.Id}
+	run := func(name string, fn func() error) {
+		if err := retrySubOperation(ctx, name, u.opts.Metrics, fn); err != nil {
+			u.opts.Metrics.observeError(err)
+			failed.names = append(failed.names, name)
+			failed.errs = append(failed.errs, err)
+		}
+	}
+	if meta.Thumbnail != "" {
+		run("thumbnail", func() error {
+			return setThumbnail(u.service, video.Id, meta.Thumbnail)
+		})
+	}
+	for _, track := range meta.Captions {
+		track := track
+		run("captions "+track.Language, func() error {
+			return uploadCaption(u.service, video.Id, track.Language, "", track.Path)
+		})
+	}
+	if u.opts.PlaylistID != "" {
+		run("playlist", func() error {
+			return addToPlaylist(u.service, u.opts.PlaylistID, video.Id, u.opts.OnConflict, u.opts.PlaylistPosition)
+		})
+	}
+	if u.opts.WaitForProcessing {
+		run("processing", func() error {
+			return waitForProcessing(u.service, video.Id)
+		})
+	}
+	if u.opts.ClaimsWindow > 0 {
+		run("claims check", func() error {
+			return waitForClaims(u.service, video.Id, u.opts.ClaimsWindow)
+		})
+	}
+	if len(failed.names) > 0 {
+		return failed
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/option"
+	"google.golang.org/api/youtube/v3"
+)
+
+// newTestService は、srvにリクエストを送る youtube.Service を返します。
+func newTestService(t *testing.T, srv *httptest.Server) *youtube.Service {
+	t.Helper()
+	service, err := youtube.NewService(context.Background(),
+		option.WithHTTPClient(srv.Client()), option.WithEndpoint(srv.URL+"/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service
+}
+
+func TestInvalidMetadataRejectedBeforeBytesAreSent(t *testing.T) {
+	var puts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			puts++
+			return
+		}
+		if r.URL.Query().Get("uploadType") != "resumable" {
+			t.Errorf("session request %s, want uploadType=resumable", r.URL)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"code":400,"message":"Invalid category","errors":[` +
+			`{"reason":"invalidCategoryId","message":"The video category is invalid.","location":"body.snippet.categoryId"}]}}`))
+	}))
+	defer srv.Close()
+
+	u := newUploader(srv.Client(), newTestService(t, srv), uploadOptions{MaxAttempts: defaultMaxAttempts, NoProgress: true})
+	meta := videoMetadata{File: "video.mp4", Title: "title", Privacy: "private", CategoryID: "9999"}
+	_, err := u.uploadReader(context.Background(), meta, bytes.NewReader([]byte("video bytes")), -1)
+
+	var metaErr *metadataError
+	if !errors.As(err, &metaErr) {
+		t.Fatalf("upload = %v, want a *metadataError", err)
+	}
+	if !strings.Contains(err.Error(), "body.snippet.categoryId: invalidCategoryId") {
+		t.Errorf("error %q does not name the rejected field", err)
+	}
+	if puts != 0 {
+		t.Errorf("%d chunks were sent after the metadata was rejected, want 0", puts)
+	}
+}
+
+func TestStrictPrivacyMismatchKeepsUploadedVideo(t *testing.T) {
+	t.Setenv(configDirEnv, t.TempDir())
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			w.Header().Set("Location", "http://"+r.Host+"/session")
+			return
+		}
+		io.Copy(io.Discard, r.Body)
+		// 要求したprivateではなくpublicで作成された
+		w.Write([]byte(`{"id":"video-id","status":{"privacyStatus":"public"}}`))
+	}))
+	defer srv.Close()
+
+	data := []byte("video bytes")
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	meta := videoMetadata{File: path, Title: "title", Privacy: "private"}
+	tests := []struct {
+		name   string
+		upload func(u *uploader) (*youtube.Video, error)
+	}{
+		{"uploaded", func(u *uploader) (*youtube.Video, error) {
+			return u.uploadReader(context.Background(), meta, bytes.NewReader(data), int64(len(data)))
+		}},
+		{"completed saved session", func(u *uploader) (*youtube.Video, error) {
+			// 前回の実行で送り終えたが、応答を受け取る前に終了したセッション
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			saved := savedSession{URI: srv.URL + "/session", File: path, Size: info.Size(), ModTime: info.ModTime(), Created: time.Now()}
+			if err := saveSession(path, saved); err != nil {
+				t.Fatal(err)
+			}
+			return u.upload(context.Background(), meta)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := newUploadMetrics()
+			u := newUploader(srv.Client(), newTestService(t, srv), uploadOptions{
+				MaxAttempts: defaultMaxAttempts, NoProgress: true, Strict: true, Metrics: metrics})
+			video, err := tt.upload(u)
+			if err == nil || !strings.Contains(err.Error(), "public") {
+				t.Errorf("upload error = %v, want the privacy mismatch", err)
+			}
+			if video == nil || video.Id != "video-id" {
+				t.Fatalf("upload = %v, want the uploaded video with the error", video)
+			}
+			if metrics.uploads != 1 {
+				t.Errorf("uploads_total = %d, want 1", metrics.uploads)
+			}
+		})
+	}
+}
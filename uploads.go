@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// listUploads は、認証されたユーザーのチャンネルにアップロードされたすべての動画を返します。
+// 動画にはsnippetとstatusが含まれます。
+func listUploads(service *youtube.Service) ([]*youtube.Video, error) {
+	channels, err := service.Channels.List([]string{"contentDetails"}).Mine(true).Do()
+	if err != nil {
+		return nil, fmt.Errorf("listing channels: %w", err)
+	}
+	if len(channels.Items) == 0 {
+		return nil, fmt.Errorf("the authenticated user has no channel")
+	}
+	uploadsID := channels.Items[0].ContentDetails.RelatedPlaylists.Uploads
+
+	var ids []string
+	call := service.PlaylistItems.List([]string{"contentDetails"}).PlaylistId(uploadsID).MaxResults(50)
+	err = call.Pages(context.Background(), func(page *youtube.PlaylistItemListResponse) error {
+		for _, item := range page.Items {
+			ids = append(ids, item.ContentDetails.VideoId)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing uploads: %w", err)
+	}
+
+	// videos.listは1回に50件までしか指定できない
+	var videos []*youtube.Video
+	for start := 0; start < len(ids); start += 50 {
+		end := start + 50
+		if end > len(ids) {
+			end = len(ids)
+		}
+		response, err := service.Videos.List([]string{"snippet", "status"}).Id(ids[start:end]...).Do()
+		if err != nil {
+			return nil, fmt.Errorf("listing videos: %w", err)
+		}
+		videos = append(videos, response.Items...)
+	}
+	return videos, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// privacyStatuses は、ローカルで受け付けるプライバシー設定です。
+var privacyStatuses = []string{"public", "unlisted", "private"}
+
+// isPrivacyStatus は、statusが privacyStatuses に含まれるかどうかを返します。
+func isPrivacyStatus(status string) bool {
+	for _, p := range privacyStatuses {
+		if status == p {
+			return true
+		}
+	}
+	return false
+}
+
+// videoCategories は、動画に割り当てられるカテゴリIDとその名前です。
+// YouTubeが新しいカテゴリを追加した場合は -no-validate で検証を省略できます。
+var videoCategories = map[string]string{
+	"1":  "Film & Animation",
+	"2":  "Autos & Vehicles",
+	"10": "Music",
+	"15": "Pets & Animals",
+	"17": "Sports",
+	"19": "Travel & Events",
+	"20": "Gaming",
+	"22": "People & Blogs",
+	"23": "Comedy",
+	"24": "Entertainment",
+	"25": "News & Politics",
+	"26": "Howto & Style",
+	"27": "Education",
+	"28": "Science & Technology",
+	"29": "Nonprofits & Activism",
+}
+
+// validateMetadata は、メタデータのプライバシー設定とカテゴリが既知の値かどうかと、録画日時の形式を検証します。
+// タグ全体が maxTagsLength を超える場合も、APIに拒否される前にエラーにします。
+// -truncate-tags を指定した場合は、検証の前にタグを切り詰めてあります。
+func validateMetadata(meta videoMetadata) error {
+	if !isPrivacyStatus(meta.Privacy) {
+		return fmt.Errorf("invalid privacy %q, must be one of %s (use -no-validate to send it anyway)",
+			meta.Privacy, strings.Join(privacyStatuses, ", "))
+	}
+	if _, ok := videoCategories[meta.CategoryID]; !ok {
+		return fmt.Errorf("unknown category ID %q (use -no-validate to send it anyway)", meta.CategoryID)
+	}
+	if n := tagsLength(meta.Tags); n > maxTagsLength {
+		return fmt.Errorf("tags total %d characters, over the %d character limit (use -truncate-tags to drop the last tags)", n, maxTagsLength)
+	}
+	if meta.RecordingDate != "" {
+		if _, err := time.Parse(time.RFC3339, meta.RecordingDate); err != nil {
+			return fmt.Errorf("invalid recording_date %q, must be RFC 3339 such as 2024-05-01T09:00:00Z", meta.RecordingDate)
+		}
+	}
+	return validateLocalizations(meta)
+}
+
+// languageCodePattern は、BCP-47の言語コードとして受け付ける形式です(en、ja、pt-BR、zh-Hant-TW など)。
+var languageCodePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// validateLocalizations は、既定の言語とローカライズの言語コードを検証します。
+// ローカライズを指定する場合は既定の言語が必要で、その言語のローカライズも含める必要があります。
+func validateLocalizations(meta videoMetadata) error {
+	if meta.DefaultLanguage != "" && !languageCodePattern.MatchString(meta.DefaultLanguage) {
+		return fmt.Errorf("invalid default_language %q, must be a BCP-47 language code such as en or pt-BR", meta.DefaultLanguage)
+	}
+	if len(meta.Localizations) == 0 {
+		return nil
+	}
+	if meta.DefaultLanguage == "" {
+		return fmt.Errorf("localizations require default_language")
+	}
+	for language, l := range meta.Localizations {
+		if !languageCodePattern.MatchString(language) {
+			return fmt.Errorf("invalid localization language %q, must be a BCP-47 language code such as en or pt-BR", language)
+		}
+		if l.Title == "" {
+			return fmt.Errorf("localization %s has no title", language)
+		}
+	}
+	if _, ok := meta.Localizations[meta.DefaultLanguage]; !ok {
+		return fmt.Errorf("localizations have no entry for default_language %s", meta.DefaultLanguage)
+	}
+	return nil
+}
+
+// videoExtensions は、YouTubeが受け付ける動画のコンテナ形式の拡張子です。
+// https://support.google.com/youtube/troubleshooter/2888402 に基づきます。
+var videoExtensions = []string{
+	".mov", ".mpeg", ".mpg", ".mp4", ".m4v", ".avi", ".wmv", ".mpegps", ".flv",
+	".3gp", ".3gpp", ".webm", ".mkv", ".mts", ".m2ts", ".ts", ".mxf",
+}
+
+// maxVideoSize は、YouTubeにアップロードできる動画ファイルの最大バイト数(256GB)です。
+const maxVideoSize = 256 << 30
+
+// validateVideoFile は、動画ファイルの拡張子が videoExtensions に含まれ、
+// 大きさが0より大きく maxVideoSize 以下であることを検証します。
+// 標準入力("-")は読み込むまで分からないため検証しません。
+func validateVideoFile(path string) error {
+	if path == stdinFile {
+		return nil
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	supported := false
+	for _, e := range videoExtensions {
+		if ext == e {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return fmt.Errorf("%s: unsupported video format %q, must be one of %s (use -no-validate to send it anyway)",
+			path, ext, strings.Join(videoExtensions, " "))
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	if info.Size() == 0 {
+		return fmt.Errorf("%s: video file is empty", path)
+	}
+	if info.Size() > maxVideoSize {
+		return fmt.Errorf("%s: video file is %d bytes, larger than the %d byte limit", path, info.Size(), int64(maxVideoSize))
+	}
+	return nil
+}
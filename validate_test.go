@@ -0,0 +1,22 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateMetadataTagsLength(t *testing.T) {
+	var tags []string
+	for i := 0; i < 60; i++ {
+		tags = append(tags, "tag"+strings.Repeat("x", 5))
+	}
+	meta := videoMetadata{Privacy: "private", CategoryID: "22", Tags: tags}
+	err := validateMetadata(meta)
+	if err == nil || !strings.Contains(err.Error(), "-truncate-tags") {
+		t.Fatalf("validateMetadata = %v, want the tags over the limit reported", err)
+	}
+	meta.Tags = truncateTags(tags)
+	if err := validateMetadata(meta); err != nil {
+		t.Errorf("validateMetadata after truncateTags = %v, want nil", err)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// -video-type に指定できる値です。
+// APIには動画の種類を直接指定する項目がないため、対応する項目の組み合わせを設定します。
+const (
+	// videoTypeVideo は、通常の動画として動画タブに表示します。メタデータはそのまま使います。
+	videoTypeVideo = "video"
+	// videoTypeShort は、#Shortsを付けてショート動画として扱われるようにします。
+	videoTypeShort = "short"
+	// videoTypeUnlisted は、コミュニティ投稿などで共有するために限定公開にします。
+	videoTypeUnlisted = "unlisted"
+)
+
+// shortsTag は、ショート動画として扱われるためにタイトルか説明に含めるハッシュタグです。
+const shortsTag = "#Shorts"
+
+// maxTitleLength は、動画のタイトルに使える最大の文字数です。
+const maxTitleLength = 100
+
+// applyVideoType は、動画の種類に応じてメタデータの項目を設定します。
+func applyVideoType(meta *videoMetadata, videoType string) error {
+	switch videoType {
+	case "", videoTypeVideo:
+		return nil
+	case videoTypeShort:
+		if strings.Contains(strings.ToLower(meta.Title+" "+meta.Description), strings.ToLower(shortsTag)) {
+			return nil
+		}
+		// タイトルに収まらない場合は説明の末尾に付ける
+		if title := meta.Title + " " + shortsTag; len([]rune(title)) <= maxTitleLength {
+			meta.Title = title
+		} else {
+			meta.Description = strings.TrimSpace(meta.Description + "\n\n" + shortsTag)
+		}
+		return nil
+	case videoTypeUnlisted:
+		if meta.Privacy != "unlisted" {
+			log.Printf("%s: -video-type unlisted overrides privacy %q", meta.File, meta.Privacy)
+			meta.Privacy = "unlisted"
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid -video-type %q, must be one of %s, %s, %s",
+		videoType, videoTypeVideo, videoTypeShort, videoTypeUnlisted)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// loadTagVocabulary は、許可するタグを1行に1つずつ記述したファイルを読み込みます。
+// 空行と#で始まる行は無視します。
+// 小文字に変換したタグから、ファイルに記述された表記への対応表を返します。
+func loadTagVocabulary(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	vocab := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		tag := strings.TrimSpace(scanner.Text())
+		if tag == "" || strings.HasPrefix(tag, "#") {
+			continue
+		}
+		vocab[strings.ToLower(tag)] = tag
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return vocab, nil
+}
+
+// applyTagVocabulary は、タグを語彙と大文字小文字を区別せずに照合し、語彙の表記に揃えます。
+// enforceがtrueの場合、語彙に含まれないタグがあれば、それらをすべて列挙したエラーを返します。
+// enforceがfalseの場合、語彙に含まれないタグはそのまま残します。
+func applyTagVocabulary(tags []string, vocab map[string]string, enforce bool) ([]string, error) {
+	var unknown []string
+	canonical := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if v, ok := vocab[strings.ToLower(tag)]; ok {
+			canonical = append(canonical, v)
+			continue
+		}
+		unknown = append(unknown, tag)
+		canonical = append(canonical, tag)
+	}
+	if enforce && len(unknown) > 0 {
+		return nil, fmt.Errorf("tags not in the vocabulary: %q", unknown)
+	}
+	return canonical, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// maxDescriptionBytes は、動画の説明に指定できる最大のバイト数です。
+const maxDescriptionBytes = 5000
+
+// parseVideoIDs は、カンマ区切りの動画IDを重複を除いて順番どおりに返します。
+func parseVideoIDs(s string) []string {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, id := range strings.Split(s, ",") {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// watchNextBlock は、動画IDをvideos.listでタイトルに解決し、説明の末尾に追加する
+// 「次に見る」リンクのブロックを組み立てます。存在しない動画IDがある場合はエラーを返します。
+func watchNextBlock(service *youtube.Service, ids []string) (string, error) {
+	response, err := service.Videos.List([]string{"snippet"}).Id(ids...).Do()
+	if err != nil {
+		return "", fmt.Errorf("looking up -watch-next videos: %w", err)
+	}
+	titles := make(map[string]string, len(response.Items))
+	for _, video := range response.Items {
+		titles[video.Id] = video.Snippet.Title
+	}
+
+	var b strings.Builder
+	b.WriteString("Watch next:\n")
+	for _, id := range ids {
+		title, ok := titles[id]
+		if !ok {
+			return "", fmt.Errorf("-watch-next video %s not found", id)
+		}
+		// 説明に山括弧を含めるとAPIが拒否するため取り除く
+		title = strings.NewReplacer("<", "", ">", "").Replace(title)
+		fmt.Fprintf(&b, "▶ %s: https://youtu.be/%s\n", title, id)
+	}
+	return b.String(), nil
+}
+
+// appendWatchNext は、説明の末尾にリンクのブロックを追加します。
+// 追加後の説明が上限を超える場合はエラーを返します。
+func appendWatchNext(description, block string) (string, error) {
+	if description != "" {
+		description = strings.TrimRight(description, "\n") + "\n\n"
+	}
+	description += block
+	if len(description) > maxDescriptionBytes {
+		return "", fmt.Errorf("description with -watch-next links is %d bytes, over the %d byte limit",
+			len(description), maxDescriptionBytes)
+	}
+	return description, nil
+}